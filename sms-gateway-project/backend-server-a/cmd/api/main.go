@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -11,6 +16,10 @@ import (
 	"sms-gateway/backend-server-a/internal/services"
 )
 
+// shutdownTimeout bounds how long in-flight requests and the outbox flush may
+// take after a termination signal.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -18,12 +27,32 @@ func main() {
 	}
 
 	rdb := services.NewRedisClient(cfg)
+	defer rdb.Close()
 	publisher, err := services.NewPublisher(cfg.RabbitMQURL, cfg.RabbitMQQueueName)
 	if err != nil {
 		log.Fatalf("rabbitmq: %v", err)
 	}
 	defer publisher.Close()
 
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	outbox := services.NewOutbox(rdb)
+	relay := services.NewOutboxRelay(outbox, publisher)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+
+	var templates api.TemplateSource
+	if cfg.TemplateServiceURL != "" {
+		templates = services.NewTemplateClient(cfg.TemplateServiceURL, cfg.InternalAPIKey)
+	}
+
 	r := gin.Default()
 
 	r.GET("/health", func(c *gin.Context) {
@@ -31,10 +60,30 @@ func main() {
 	})
 
 	v1 := r.Group("/v1")
-	v1.Use(api.AuthMiddleware(cfg), api.QuotaMiddleware(rdb))
-	v1.POST("/sms/send", api.SendSMSHandler(cfg, rdb, publisher))
+	v1.Use(api.AuthMiddleware(cfg))
+	v1.POST("/sms/send", api.QuotaMiddleware(rdb), api.SendSMSHandler(cfg, rdb, outbox, templates))
+	// the bulk handler charges the quota per recipient itself
+	v1.POST("/sms/bulk", api.BulkSendSMSHandler(cfg, rdb, outbox))
+
+	srv := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-	if err := r.Run(cfg.ListenAddr); err != nil {
-		log.Fatal(err)
+	<-sigCtx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	// stop the relay, then publish what the last requests accepted
+	cancel()
+	<-relayDone
+	if err := relay.Flush(shutdownCtx); err != nil {
+		log.Printf("outbox flush: %v", err)
 	}
 }
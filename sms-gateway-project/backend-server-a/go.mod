@@ -3,10 +3,14 @@ module sms-gateway/backend-server-a
 go 1.21
 
 require (
+    github.com/alicebob/miniredis/v2 v2.31.1
     github.com/gin-gonic/gin v1.10.0
     github.com/redis/go-redis/v9 v9.5.1
     github.com/rabbitmq/amqp091-go v1.10.0
     github.com/joho/godotenv v1.5.1
     github.com/google/uuid v1.3.1
+    sms-gateway/shared v0.0.0
 )
 
+replace sms-gateway/shared => ../shared
+
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,16 +16,26 @@ import (
 	"sms-gateway/backend-server-a/internal/config"
 	"sms-gateway/backend-server-a/internal/models"
 	"sms-gateway/backend-server-a/internal/services"
+	"sms-gateway/shared/phone"
+	"sms-gateway/shared/smsenc"
+	"sms-gateway/shared/template"
 )
 
-func SendSMSHandler(cfg *config.Config, rdb *redis.Client, publisher *services.RabbitMQPublisher) gin.HandlerFunc {
+// TemplateSource looks up message templates by ID.
+type TemplateSource interface {
+	Get(ctx context.Context, id string) (template.Template, error)
+}
+
+// SendSMSHandler accepts a message into the outbox; the outbox relay publishes
+// it to RabbitMQ. The idempotency response is stored in the same Redis
+// transaction, so a retried request never enqueues the message twice.
+// Requests naming a template are rendered through templates, which may be nil
+// when no template service is configured.
+func SendSMSHandler(cfg *config.Config, rdb *redis.Client, outbox *services.Outbox, templates TemplateSource) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idKey := c.GetHeader("Idempotency-Key")
-		if idKey != "" {
-			if val, err := rdb.Get(c, "idem:"+idKey).Result(); err == nil {
-				c.Data(http.StatusOK, "application/json", []byte(val))
-				return
-			}
+		if replayIdempotent(c, rdb, idKey) {
+			return
 		}
 
 		var req SendSMSRequest
@@ -30,28 +44,274 @@ func SendSMSHandler(cfg *config.Config, rdb *redis.Client, publisher *services.R
 			return
 		}
 
-		trackingID := uuid.New().String()
-		payload := models.MessagePayload{
-			TrackingID: trackingID,
-			Recipient:  req.Recipient,
-			Message:    req.Message,
-			Providers:  req.Providers,
-			TTL:        req.TTL,
+		if err := validateSendAt(req.SendAt); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+
+		recipient, err := phone.Normalize(req.Recipient, cfg.DefaultCountry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+		text, status, err := messageText(c, templates, req)
+		if err != nil {
+			c.JSON(status, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+		if err := checkSegments(text, cfg.MaxSegments); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+
+		payload := newPayload(recipient, text, req.Providers, req.TTL, req.SendAt)
+		payload.Client = clientName(c)
+		resp := AcceptedResponse{Success: true, Message: "accepted", TrackingID: payload.TrackingID, SendAt: payload.SendAt}
+		body, err := json.Marshal(resp)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "failed to encode response"})
+			return
 		}
 
-		if err := publisher.Publish(c, payload); err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "failed to publish message"})
+		if err := enqueue(c, rdb, outbox, idKey, body, payload); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "failed to queue message"})
 			return
 		}
 
-		resp := AcceptedResponse{Success: true, Message: "accepted", TrackingID: trackingID}
+		c.Data(http.StatusAccepted, "application/json", body)
+	}
+}
 
-		if idKey != "" {
-			if b, err := json.Marshal(resp); err == nil {
-				rdb.Set(c, "idem:"+idKey, b, 24*time.Hour)
+// BulkSendSMSHandler accepts many messages in one request. Every entry is
+// validated before anything is queued, the client's quota is charged per
+// message, and all messages are written to the outbox in one transaction
+// under a shared batch ID. Unlike SendSMSHandler it is not wrapped in
+// QuotaMiddleware since the charge depends on the request body.
+func BulkSendSMSHandler(cfg *config.Config, rdb *redis.Client, outbox *services.Outbox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idKey := c.GetHeader("Idempotency-Key")
+		if replayIdempotent(c, rdb, idKey) {
+			return
+		}
+
+		var req BulkSendSMSRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+		if err := validateSendAt(req.SendAt); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error()})
+			return
+		}
+		payloads, errs := buildBulkPayloads(req, cfg)
+		if len(errs) > 0 {
+			c.JSON(http.StatusBadRequest, BulkErrorResponse{Success: false, Message: "invalid messages", Errors: errs})
+			return
+		}
+
+		for i := range payloads {
+			payloads[i].Client = clientName(c)
+		}
+
+		ok, err := chargeQuota(c, rdb, len(payloads))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "quota check failed"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusTooManyRequests, ErrorResponse{Success: false, Message: "daily quota exceeded"})
+			return
+		}
+
+		resp := BulkAcceptedResponse{Success: true, Message: "accepted", BatchID: payloads[0].BatchID, SendAt: payloads[0].SendAt}
+		for _, p := range payloads {
+			resp.Messages = append(resp.Messages, BulkAcceptedMessage{Recipient: p.Recipient, TrackingID: p.TrackingID})
+		}
+		body, err := json.Marshal(resp)
+		if err != nil {
+			refundQuota(c, rdb, len(payloads))
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "failed to encode response"})
+			return
+		}
+
+		if err := enqueue(c, rdb, outbox, idKey, body, payloads...); err != nil {
+			refundQuota(c, rdb, len(payloads))
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "failed to queue messages"})
+			return
+		}
+
+		c.Data(http.StatusAccepted, "application/json", body)
+	}
+}
+
+// messageText returns the text of a send request: its message, or its
+// template rendered with its variables. On failure it also returns the HTTP
+// status to answer with.
+func messageText(ctx context.Context, templates TemplateSource, req SendSMSRequest) (string, int, error) {
+	switch {
+	case req.Message != "" && req.TemplateID != "":
+		return "", http.StatusBadRequest, errors.New("message and template_id are mutually exclusive")
+	case req.TemplateID == "":
+		if req.Message == "" {
+			return "", http.StatusBadRequest, errors.New("message or template_id is required")
+		}
+		return req.Message, 0, nil
+	case templates == nil:
+		return "", http.StatusServiceUnavailable, errors.New("templates are not available")
+	}
+
+	tpl, err := templates.Get(ctx, req.TemplateID)
+	if errors.Is(err, services.ErrTemplateNotFound) {
+		return "", http.StatusBadRequest, fmt.Errorf("unknown template %q", req.TemplateID)
+	}
+	if err != nil {
+		return "", http.StatusBadGateway, errors.New("template lookup failed")
+	}
+	language := req.Language
+	if language == "" {
+		language = tpl.DefaultLanguage
+	}
+	variant, ok := tpl.Variant(language)
+	if !ok {
+		return "", http.StatusBadRequest, fmt.Errorf("template %q has no %q variant", req.TemplateID, language)
+	}
+	text, err := template.Render(variant.Body, req.Variables)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	return text, 0, nil
+}
+
+// buildBulkPayloads validates a bulk request and expands it into one payload
+// per recipient, all carrying the same batch ID. Recipients are normalized
+// to E.164, and messages longer than cfg.MaxSegments parts are rejected.
+func buildBulkPayloads(req BulkSendSMSRequest, cfg *config.Config) ([]models.MessagePayload, []BulkError) {
+	entries := make([]BulkMessage, 0, len(req.Recipients)+len(req.Messages))
+	for _, r := range req.Recipients {
+		entries = append(entries, BulkMessage{Recipient: r, Message: req.Message})
+	}
+	for _, m := range req.Messages {
+		if m.Message == "" {
+			m.Message = req.Message
+		}
+		entries = append(entries, m)
+	}
+
+	switch {
+	case len(entries) == 0:
+		return nil, []BulkError{{Index: -1, Error: "at least one recipient is required"}}
+	case len(entries) > MaxBulkMessages:
+		return nil, []BulkError{{Index: -1, Error: fmt.Sprintf("at most %d messages are allowed per request", MaxBulkMessages)}}
+	}
+
+	var errs []BulkError
+	for i, e := range entries {
+		recipient, err := phone.Normalize(e.Recipient, cfg.DefaultCountry)
+		switch {
+		case strings.TrimSpace(e.Recipient) == "":
+			errs = append(errs, BulkError{Index: i, Recipient: e.Recipient, Error: "recipient is required"})
+		case err != nil:
+			errs = append(errs, BulkError{Index: i, Recipient: e.Recipient, Error: err.Error()})
+		case e.Message == "":
+			errs = append(errs, BulkError{Index: i, Recipient: e.Recipient, Error: "message is required"})
+		default:
+			if err := checkSegments(e.Message, cfg.MaxSegments); err != nil {
+				errs = append(errs, BulkError{Index: i, Recipient: e.Recipient, Error: err.Error()})
 			}
 		}
+		entries[i].Recipient = recipient
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-		c.JSON(http.StatusAccepted, resp)
+	batchID := uuid.New().String()
+	payloads := make([]models.MessagePayload, 0, len(entries))
+	for _, e := range entries {
+		p := newPayload(e.Recipient, e.Message, req.Providers, req.TTL, req.SendAt)
+		p.BatchID = batchID
+		p.BatchSize = len(entries)
+		payloads = append(payloads, p)
 	}
+	return payloads, nil
+}
+
+// checkSegments rejects text that takes more than max SMS parts; a max of 0
+// allows any length.
+func checkSegments(text string, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	if info := smsenc.Analyze(text); info.Segments > max {
+		return fmt.Errorf("message takes %d %s segments, at most %d are allowed", info.Segments, info.Encoding, max)
+	}
+	return nil
+}
+
+// newPayload builds the queue message for one recipient with a fresh tracking
+// ID. The TTL of a scheduled message runs from its send time.
+func newPayload(recipient, text string, providers []string, ttl int, sendAt *time.Time) models.MessagePayload {
+	payload := models.MessagePayload{
+		TrackingID: uuid.New().String(),
+		Recipient:  recipient,
+		Text:       text,
+		Providers:  providers,
+		TTL:        ttl,
+	}
+	start := time.Now().UTC()
+	if sendAt != nil {
+		at := sendAt.UTC()
+		payload.SendAt = &at
+		start = at
+	}
+	if ttl > 0 {
+		expiresAt := start.Add(time.Duration(ttl) * time.Second)
+		payload.ExpiresAt = &expiresAt
+	}
+	return payload
+}
+
+// validateSendAt checks that a requested send time lies in the future, but
+// not too far.
+func validateSendAt(sendAt *time.Time) error {
+	if sendAt == nil {
+		return nil
+	}
+	now := time.Now()
+	if !sendAt.After(now) {
+		return errors.New("send_at must be in the future")
+	}
+	if sendAt.After(now.Add(MaxScheduleAhead)) {
+		return fmt.Errorf("send_at must be within %d days", int(MaxScheduleAhead/(24*time.Hour)))
+	}
+	return nil
+}
+
+// replayIdempotent answers a request whose Idempotency-Key was seen before
+// with the stored response and reports whether it did so.
+func replayIdempotent(c *gin.Context, rdb *redis.Client, idKey string) bool {
+	if idKey == "" {
+		return false
+	}
+	val, err := rdb.Get(c, "idem:"+idKey).Result()
+	if err != nil {
+		return false
+	}
+	c.Data(http.StatusOK, "application/json", []byte(val))
+	return true
+}
+
+// enqueue writes payloads to the outbox and, when idKey is set, the response
+// body for idempotent replays, in one Redis transaction.
+func enqueue(c *gin.Context, rdb *redis.Client, outbox *services.Outbox, idKey string, body []byte, payloads ...models.MessagePayload) error {
+	_, err := rdb.TxPipelined(c, func(pipe redis.Pipeliner) error {
+		if err := outbox.Add(c, pipe, payloads...); err != nil {
+			return err
+		}
+		if idKey != "" {
+			pipe.Set(c, "idem:"+idKey, body, 24*time.Hour)
+		}
+		return nil
+	})
+	return err
 }
@@ -0,0 +1,175 @@
+package api
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "sms-gateway/backend-server-a/internal/config"
+    "sms-gateway/backend-server-a/internal/services"
+    "sms-gateway/shared/template"
+)
+
+// testConfig is the configuration the handler helpers are tested with.
+var testConfig = &config.Config{DefaultCountry: "IR"}
+
+func TestBuildBulkPayloads(t *testing.T) {
+    payloads, errs := buildBulkPayloads(BulkSendSMSRequest{
+        Recipients: []string{"09120000001", "+989130000002"},
+        Messages:   []BulkMessage{{Recipient: "989140000003", Message: "custom"}, {Recipient: "9150000004"}},
+        Message:    "hello",
+        Providers:  []string{"magfa"},
+        TTL:        60,
+    }, testConfig)
+    if len(errs) != 0 {
+        t.Fatalf("unexpected errors: %+v", errs)
+    }
+    if len(payloads) != 4 {
+        t.Fatalf("expected 4 payloads, got %d", len(payloads))
+    }
+    texts := []string{"hello", "hello", "custom", "hello"}
+    recipients := []string{"+989120000001", "+989130000002", "+989140000003", "+989150000004"}
+    seen := map[string]bool{}
+    for i, p := range payloads {
+        if p.Text != texts[i] {
+            t.Errorf("payload %d: text = %q", i, p.Text)
+        }
+        if p.Recipient != recipients[i] {
+            t.Errorf("payload %d: recipient = %q", i, p.Recipient)
+        }
+        if p.BatchID == "" || p.BatchID != payloads[0].BatchID || p.BatchSize != 4 {
+            t.Errorf("payload %d: batch = %q/%d", i, p.BatchID, p.BatchSize)
+        }
+        if p.ExpiresAt == nil || len(p.Providers) != 1 {
+            t.Errorf("payload %d: %+v", i, p)
+        }
+        if seen[p.TrackingID] {
+            t.Errorf("payload %d: duplicate tracking id", i)
+        }
+        seen[p.TrackingID] = true
+    }
+}
+
+func TestBuildBulkPayloadsReportsEveryInvalidEntry(t *testing.T) {
+    _, errs := buildBulkPayloads(BulkSendSMSRequest{
+        Recipients: []string{"09120000001", " ", "0912"},
+        Messages:   []BulkMessage{{Recipient: "09140000003"}},
+    }, testConfig)
+    if len(errs) != 4 {
+        t.Fatalf("expected 4 errors, got %+v", errs)
+    }
+    if errs[1].Index != 1 || errs[1].Error != "recipient is required" {
+        t.Errorf("errs[1] = %+v", errs[1])
+    }
+    if errs[2].Index != 2 || !strings.HasPrefix(errs[2].Error, "invalid phone number") {
+        t.Errorf("errs[2] = %+v", errs[2])
+    }
+}
+
+func TestBuildBulkPayloadsLimits(t *testing.T) {
+    if _, errs := buildBulkPayloads(BulkSendSMSRequest{Message: "hi"}, testConfig); len(errs) != 1 {
+        t.Errorf("empty request: %+v", errs)
+    }
+    recipients := strings.Split(strings.Repeat("09120000001,", MaxBulkMessages+1), ",")[:MaxBulkMessages+1]
+    if _, errs := buildBulkPayloads(BulkSendSMSRequest{Recipients: recipients, Message: "hi"}, testConfig); len(errs) != 1 {
+        t.Errorf("oversized request: %+v", errs)
+    }
+}
+
+func TestValidateSendAt(t *testing.T) {
+    past := time.Now().Add(-time.Minute)
+    future := time.Now().Add(time.Hour)
+    tooFar := time.Now().Add(MaxScheduleAhead + time.Hour)
+    if err := validateSendAt(nil); err != nil {
+        t.Errorf("nil: %v", err)
+    }
+    if err := validateSendAt(&future); err != nil {
+        t.Errorf("future: %v", err)
+    }
+    if err := validateSendAt(&past); err == nil {
+        t.Error("past send_at accepted")
+    }
+    if err := validateSendAt(&tooFar); err == nil {
+        t.Error("send_at beyond the schedule window accepted")
+    }
+}
+
+func TestNewPayloadScheduledTTL(t *testing.T) {
+    sendAt := time.Now().Add(time.Hour)
+    p := newPayload("0912", "hi", nil, 60, &sendAt)
+    if p.SendAt == nil || !p.SendAt.Equal(sendAt) {
+        t.Fatalf("SendAt = %v", p.SendAt)
+    }
+    if p.ExpiresAt == nil || !p.ExpiresAt.Equal(sendAt.Add(time.Minute)) {
+        t.Errorf("TTL must run from send_at, ExpiresAt = %v", p.ExpiresAt)
+    }
+}
+
+// fakeTemplates serves templates from a map.
+type fakeTemplates map[string]template.Template
+
+func (f fakeTemplates) Get(ctx context.Context, id string) (template.Template, error) {
+    tpl, ok := f[id]
+    if !ok {
+        return template.Template{}, services.ErrTemplateNotFound
+    }
+    return tpl, nil
+}
+
+func TestMessageText(t *testing.T) {
+    templates := fakeTemplates{"otp": {ID: "otp", DefaultLanguage: "fa", Variants: []template.Variant{
+        {Language: "fa", Body: "کد شما {{code}}"},
+        {Language: "en", Body: "Hi {{name}}, your code is {{code}}"},
+    }}}
+    cases := []struct {
+        name   string
+        req    SendSMSRequest
+        text   string
+        status int
+    }{
+        {"raw message", SendSMSRequest{Message: "hello"}, "hello", 0},
+        {"default language", SendSMSRequest{TemplateID: "otp", Variables: map[string]string{"code": "1234"}}, "کد شما 1234", 0},
+        {"chosen language", SendSMSRequest{TemplateID: "otp", Language: "en", Variables: map[string]string{"code": "1", "name": "Sara"}}, "Hi Sara, your code is 1", 0},
+        {"missing variables", SendSMSRequest{TemplateID: "otp", Language: "en", Variables: map[string]string{"code": "1"}}, "", http.StatusBadRequest},
+        {"unknown language", SendSMSRequest{TemplateID: "otp", Language: "de"}, "", http.StatusBadRequest},
+        {"unknown template", SendSMSRequest{TemplateID: "nope"}, "", http.StatusBadRequest},
+        {"both", SendSMSRequest{Message: "hi", TemplateID: "otp"}, "", http.StatusBadRequest},
+        {"neither", SendSMSRequest{}, "", http.StatusBadRequest},
+    }
+    for _, tc := range cases {
+        text, status, err := messageText(context.Background(), templates, tc.req)
+        if text != tc.text || status != tc.status || (err != nil) != (tc.status != 0) {
+            t.Errorf("%s: got %q %d %v", tc.name, text, status, err)
+        }
+    }
+
+    if _, status, err := messageText(context.Background(), nil, SendSMSRequest{TemplateID: "otp"}); err == nil || status != http.StatusServiceUnavailable {
+        t.Errorf("without templates: got %d %v", status, err)
+    }
+}
+
+func TestCheckSegments(t *testing.T) {
+    long := strings.Repeat("س", 135)
+    if err := checkSegments(long, 0); err != nil {
+        t.Errorf("no limit: %v", err)
+    }
+    if err := checkSegments(long, 3); err != nil {
+        t.Errorf("within limit: %v", err)
+    }
+    if err := checkSegments(long, 2); err == nil || !strings.Contains(err.Error(), "3 UCS-2 segments") {
+        t.Errorf("over limit: %v", err)
+    }
+}
+
+func TestBuildBulkPayloadsMaxSegments(t *testing.T) {
+    _, errs := buildBulkPayloads(BulkSendSMSRequest{
+        Recipients: []string{"09120000001"},
+        Message:    "short",
+        Messages:   []BulkMessage{{Recipient: "09130000002", Message: strings.Repeat("a", 161)}},
+    }, &config.Config{DefaultCountry: "IR", MaxSegments: 1})
+    if len(errs) != 1 || errs[0].Index != 1 {
+        t.Fatalf("expected the long message to be rejected, got %+v", errs)
+    }
+}
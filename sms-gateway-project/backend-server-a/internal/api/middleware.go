@@ -25,28 +25,63 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// QuotaMiddleware charges one message against the client's daily quota.
 func QuotaMiddleware(rdb *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientVal, exists := c.Get("client")
-		if !exists {
-			c.Next()
-			return
-		}
-		client := clientVal.(config.ClientInfo)
-		apiKey := c.GetString("apiKey")
-		key := fmt.Sprintf("quota:%s:%s", apiKey, time.Now().Format("2006-01-02"))
-		count, err := rdb.Incr(c, key).Result()
+		ok, err := chargeQuota(c, rdb, 1)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Success: false, Message: "quota check failed"})
 			return
 		}
-		if count == 1 {
-			rdb.Expire(c, key, 24*time.Hour)
-		}
-		if int(count) > client.DailyQuota {
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorResponse{Success: false, Message: "daily quota exceeded"})
 			return
 		}
 		c.Next()
 	}
 }
+
+// chargeQuota adds n messages to the authenticated client's count for today
+// and reports whether they fit in its daily quota. A charge that does not fit
+// is taken back, so rejected requests do not use up the quota. Requests
+// without a client are not charged.
+func chargeQuota(c *gin.Context, rdb *redis.Client, n int) (bool, error) {
+	clientVal, exists := c.Get("client")
+	if !exists {
+		return true, nil
+	}
+	client := clientVal.(config.ClientInfo)
+	key := quotaKey(c.GetString("apiKey"))
+	count, err := rdb.IncrBy(c, key, int64(n)).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == int64(n) {
+		rdb.Expire(c, key, 24*time.Hour)
+	}
+	if int(count) > client.DailyQuota {
+		rdb.DecrBy(c, key, int64(n))
+		return false, nil
+	}
+	return true, nil
+}
+
+// refundQuota takes back a charge for messages that were not accepted.
+func refundQuota(c *gin.Context, rdb *redis.Client, n int) {
+	if _, exists := c.Get("client"); exists {
+		rdb.DecrBy(c, quotaKey(c.GetString("apiKey")), int64(n))
+	}
+}
+
+// clientName returns the name of the authenticated client, or "" if there is none.
+func clientName(c *gin.Context) string {
+	if v, ok := c.Get("client"); ok {
+		return v.(config.ClientInfo).Name
+	}
+	return ""
+}
+
+// quotaKey is the Redis key counting a client's messages for today.
+func quotaKey(apiKey string) string {
+	return fmt.Sprintf("quota:%s:%s", apiKey, time.Now().Format("2006-01-02"))
+}
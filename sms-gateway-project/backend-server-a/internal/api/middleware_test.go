@@ -5,7 +5,9 @@ import (
     "net/http/httptest"
     "testing"
 
+    "github.com/alicebob/miniredis/v2"
     "github.com/gin-gonic/gin"
+    "github.com/redis/go-redis/v9"
 
     "sms-gateway/backend-server-a/internal/config"
 )
@@ -26,6 +28,9 @@ func TestAuthMiddleware(t *testing.T) {
         if _, exists := c.Get("client"); !exists {
             t.Error("client not set")
         }
+        if name := clientName(c); name != "good" {
+            t.Errorf("clientName = %q", name)
+        }
         c.Status(http.StatusOK)
     })
 
@@ -54,3 +59,24 @@ func TestAuthMiddleware(t *testing.T) {
     }
 }
 
+
+func TestChargeQuotaRefundsRejectedRequest(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mr := miniredis.RunT(t)
+    rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+    c, _ := gin.CreateTestContext(httptest.NewRecorder())
+    c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/sms/bulk", nil)
+    c.Set("client", config.ClientInfo{Name: "good", IsActive: true, DailyQuota: 5})
+    c.Set("apiKey", "good")
+
+    if ok, err := chargeQuota(c, rdb, 3); err != nil || !ok {
+        t.Fatalf("expected the first charge to fit, got %v %v", ok, err)
+    }
+    if ok, err := chargeQuota(c, rdb, 3); err != nil || ok {
+        t.Fatalf("expected the second charge to be rejected, got %v %v", ok, err)
+    }
+    if got, _ := rdb.Get(c, quotaKey("good")).Int(); got != 3 {
+        t.Fatalf("expected the counter to stay at 3, got %d", got)
+    }
+}
@@ -1,18 +1,78 @@
 package api
 
+import "time"
+
+// SendSMSRequest carries either a raw Message or a TemplateID with the
+// Variables to fill in; Language picks the template variant.
 type SendSMSRequest struct {
-	Recipient string   `json:"recipient" binding:"required"`
-	Message   string   `json:"message" binding:"required"`
-	Providers []string `json:"providers"`
-	TTL       int      `json:"ttl"`
+	Recipient  string            `json:"recipient" binding:"required"`
+	Message    string            `json:"message"`
+	TemplateID string            `json:"template_id"`
+	Variables  map[string]string `json:"variables"`
+	Language   string            `json:"language"`
+	Providers  []string          `json:"providers"`
+	TTL        int               `json:"ttl" binding:"gte=0"`
+	// SendAt schedules the message for a future time.
+	SendAt *time.Time `json:"send_at"`
+}
+
+// MaxBulkMessages is the largest number of messages one bulk request may carry.
+const MaxBulkMessages = 1000
+
+// MaxScheduleAhead is how far in the future a message may be scheduled.
+const MaxScheduleAhead = 90 * 24 * time.Hour
+
+// BulkMessage is one recipient of a bulk request, optionally with its own text.
+type BulkMessage struct {
+	Recipient string `json:"recipient"`
+	Message   string `json:"message"`
+}
+
+// BulkSendSMSRequest sends Message to every entry of Recipients, and each
+// entry of Messages with its own text (or Message when it has none).
+type BulkSendSMSRequest struct {
+	Recipients []string      `json:"recipients"`
+	Messages   []BulkMessage `json:"messages"`
+	Message    string        `json:"message"`
+	Providers  []string      `json:"providers"`
+	TTL        int           `json:"ttl" binding:"gte=0"`
+	SendAt     *time.Time    `json:"send_at"`
 }
 
 type AcceptedResponse struct {
-	Success    bool   `json:"success"`
-	Message    string `json:"message"`
+	Success    bool       `json:"success"`
+	Message    string     `json:"message"`
+	TrackingID string     `json:"tracking_id"`
+	SendAt     *time.Time `json:"send_at,omitempty"`
+}
+
+type BulkAcceptedResponse struct {
+	Success  bool                  `json:"success"`
+	Message  string                `json:"message"`
+	BatchID  string                `json:"batch_id"`
+	SendAt   *time.Time            `json:"send_at,omitempty"`
+	Messages []BulkAcceptedMessage `json:"messages"`
+}
+
+type BulkAcceptedMessage struct {
+	Recipient  string `json:"recipient"`
 	TrackingID string `json:"tracking_id"`
 }
 
+// BulkError describes why one entry of a bulk request was rejected; Index
+// counts Recipients first and then Messages.
+type BulkError struct {
+	Index     int    `json:"index"`
+	Recipient string `json:"recipient"`
+	Error     string `json:"error"`
+}
+
+type BulkErrorResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Errors  []BulkError `json:"errors"`
+}
+
 type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
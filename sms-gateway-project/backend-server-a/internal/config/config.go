@@ -2,10 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
+
+	"sms-gateway/shared/phone"
 )
 
 type ClientInfo struct {
@@ -22,6 +26,14 @@ type Config struct {
 	RabbitMQURL       string
 	RabbitMQQueueName string
 	Clients           map[string]ClientInfo
+	// TemplateServiceURL is server B's base URL, used to look up templates.
+	TemplateServiceURL string
+	// InternalAPIKey authenticates this server on server B's /internal endpoints.
+	InternalAPIKey string
+	// MaxSegments rejects messages longer than this many SMS parts; 0 means no limit.
+	MaxSegments int
+	// DefaultCountry is the ISO code national recipient numbers belong to.
+	DefaultCountry string
 }
 
 func LoadConfig() (*Config, error) {
@@ -40,6 +52,22 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.RabbitMQURL = os.Getenv("RABBITMQ_URL")
 	cfg.RabbitMQQueueName = os.Getenv("RABBITMQ_QUEUE_NAME")
+	cfg.TemplateServiceURL = os.Getenv("TEMPLATE_SERVICE_URL")
+	cfg.InternalAPIKey = os.Getenv("INTERNAL_API_KEY")
+	cfg.DefaultCountry = phone.DefaultCountry
+	if v := os.Getenv("DEFAULT_COUNTRY"); v != "" {
+		cfg.DefaultCountry = strings.ToUpper(v)
+	}
+	if _, ok := phone.Countries[cfg.DefaultCountry]; !ok {
+		return nil, fmt.Errorf("unsupported DEFAULT_COUNTRY %q", cfg.DefaultCountry)
+	}
+	if v := os.Getenv("MAX_SEGMENTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MaxSegments = n
+	}
 
 	cfg.Clients = make(map[string]ClientInfo)
 	if cc := os.Getenv("CLIENT_CONFIG"); cc != "" {
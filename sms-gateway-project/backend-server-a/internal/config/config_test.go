@@ -59,3 +59,38 @@ func TestLoadConfigInvalidClientConfig(t *testing.T) {
     }
 }
 
+func TestLoadConfigMaxSegments(t *testing.T) {
+    t.Setenv("MAX_SEGMENTS", "3")
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if cfg.MaxSegments != 3 {
+        t.Errorf("MaxSegments = %d", cfg.MaxSegments)
+    }
+
+    t.Setenv("MAX_SEGMENTS", "three")
+    if _, err := LoadConfig(); err == nil {
+        t.Fatal("expected error for invalid MAX_SEGMENTS")
+    }
+}
+
+func TestLoadConfigDefaultCountry(t *testing.T) {
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if cfg.DefaultCountry != "IR" {
+        t.Errorf("DefaultCountry = %s", cfg.DefaultCountry)
+    }
+
+    t.Setenv("DEFAULT_COUNTRY", "ae")
+    if cfg, err := LoadConfig(); err != nil || cfg.DefaultCountry != "AE" {
+        t.Errorf("DefaultCountry = %v %v", cfg, err)
+    }
+
+    t.Setenv("DEFAULT_COUNTRY", "XX")
+    if _, err := LoadConfig(); err == nil {
+        t.Fatal("expected error for unsupported DEFAULT_COUNTRY")
+    }
+}
@@ -1,9 +1,6 @@
 package models
 
-type MessagePayload struct {
-	TrackingID string   `json:"tracking_id"`
-	Recipient  string   `json:"recipient"`
-	Message    string   `json:"message"`
-	Providers  []string `json:"providers"`
-	TTL        int      `json:"ttl"`
-}
+import "sms-gateway/shared/contract"
+
+// MessagePayload is the queue message shared with server B.
+type MessagePayload = contract.MessagePayload
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// confirmChannel is the part of *amqp.Channel used for confirmed publishing.
+type confirmChannel interface {
+	PublishWithDeferredConfirmWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) (*amqp.DeferredConfirmation, error)
+	IsClosed() bool
+	Close() error
+}
+
+// channelPool hands out confirm-mode channels so concurrent publishers never
+// share one. Channels closed by the broker, including every channel of a
+// dropped connection, are discarded instead of being reused.
+type channelPool struct {
+	open func() (confirmChannel, error)
+	idle chan confirmChannel
+}
+
+func newChannelPool(size int, open func() (confirmChannel, error)) *channelPool {
+	return &channelPool{open: open, idle: make(chan confirmChannel, size)}
+}
+
+// get returns an idle channel or opens a new one.
+func (p *channelPool) get() (confirmChannel, error) {
+	for {
+		select {
+		case ch := <-p.idle:
+			if ch.IsClosed() {
+				continue
+			}
+			return ch, nil
+		default:
+			return p.open()
+		}
+	}
+}
+
+// put returns ch to the pool, closing it when the pool is full.
+func (p *channelPool) put(ch confirmChannel) {
+	if ch.IsClosed() {
+		return
+	}
+	select {
+	case p.idle <- ch:
+	default:
+		ch.Close()
+	}
+}
+
+// close closes every idle channel.
+func (p *channelPool) close() {
+	for {
+		select {
+		case ch := <-p.idle:
+			ch.Close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type fakeChannel struct {
+    id     int
+    closed bool
+}
+
+func (f *fakeChannel) PublishWithDeferredConfirmWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) (*amqp.DeferredConfirmation, error) {
+    return nil, nil
+}
+
+func (f *fakeChannel) IsClosed() bool { return f.closed }
+
+func (f *fakeChannel) Close() error {
+    f.closed = true
+    return nil
+}
+
+func newFakePool(size int) (*channelPool, *int) {
+    opened := 0
+    return newChannelPool(size, func() (confirmChannel, error) {
+        opened++
+        return &fakeChannel{id: opened}, nil
+    }), &opened
+}
+
+func TestChannelPoolReusesIdleChannels(t *testing.T) {
+    pool, opened := newFakePool(2)
+    ch, _ := pool.get()
+    pool.put(ch)
+    again, _ := pool.get()
+    if again != ch || *opened != 1 {
+        t.Errorf("expected reuse, opened = %d", *opened)
+    }
+}
+
+func TestChannelPoolDiscardsClosedChannels(t *testing.T) {
+    pool, opened := newFakePool(2)
+    ch, _ := pool.get()
+    pool.put(ch)
+    // e.g. the connection dropped while the channel was idle
+    ch.Close()
+    fresh, _ := pool.get()
+    if fresh == ch || *opened != 2 {
+        t.Errorf("expected a new channel, opened = %d", *opened)
+    }
+}
+
+func TestChannelPoolClosesOverflow(t *testing.T) {
+    pool, _ := newFakePool(1)
+    a, _ := pool.get()
+    b, _ := pool.get()
+    pool.put(a)
+    pool.put(b)
+    if a.IsClosed() || !b.IsClosed() {
+        t.Errorf("a closed = %v, b closed = %v", a.IsClosed(), b.IsClosed())
+    }
+    pool.close()
+    if !a.IsClosed() {
+        t.Error("idle channel not closed")
+    }
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConnected is returned while the broker connection is being re-established.
+var ErrNotConnected = errors.New("rabbitmq: not connected")
+
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// ConnectionManager keeps an AMQP connection open. When the broker closes the
+// connection it reconnects with exponential backoff and runs setup again on
+// the new connection.
+type ConnectionManager struct {
+	url   string
+	setup func(*amqp.Connection) error
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConnectionManager dials url and runs setup on the connection. The
+// initial dial is not retried so misconfiguration surfaces at startup.
+func NewConnectionManager(url string, setup func(*amqp.Connection) error) (*ConnectionManager, error) {
+	m := &ConnectionManager{url: url, setup: setup, done: make(chan struct{})}
+	conn, err := m.dial()
+	if err != nil {
+		return nil, err
+	}
+	m.conn = conn
+	go m.watch(conn)
+	return m, nil
+}
+
+// Connection returns the current connection, or ErrNotConnected while a
+// reconnect is in progress.
+func (m *ConnectionManager) Connection() (*amqp.Connection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.conn == nil || m.conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+	return m.conn, nil
+}
+
+// Close closes the connection and stops reconnecting.
+func (m *ConnectionManager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.conn != nil {
+			m.conn.Close()
+			m.conn = nil
+		}
+	})
+}
+
+// dial opens a connection and prepares it with setup.
+func (m *ConnectionManager) dial() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(m.url)
+	if err != nil {
+		return nil, err
+	}
+	if m.setup != nil {
+		if err := m.setup(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// watch waits for conn to close and then reconnects.
+func (m *ConnectionManager) watch(conn *amqp.Connection) {
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	select {
+	case <-m.done:
+		return
+	case err := <-closed:
+		log.Printf("rabbitmq: connection closed: %v", err)
+	}
+
+	m.mu.Lock()
+	if m.conn == conn {
+		m.conn = nil
+	}
+	m.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(reconnectDelay(attempt)):
+		}
+		conn, err := m.dial()
+		if err != nil {
+			log.Printf("rabbitmq: reconnect attempt %d: %v", attempt+1, err)
+			continue
+		}
+		m.mu.Lock()
+		select {
+		case <-m.done:
+			// closed while dialling
+			m.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		m.conn = conn
+		m.mu.Unlock()
+		log.Printf("rabbitmq: reconnected")
+		go m.watch(conn)
+		return
+	}
+}
+
+// reconnectDelay returns the wait before the given (0-based) reconnect attempt.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectMinDelay
+	for i := 0; i < attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, reconnectMaxDelay)
+}
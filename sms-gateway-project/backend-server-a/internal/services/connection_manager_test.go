@@ -0,0 +1,21 @@
+package services
+
+import (
+    "testing"
+    "time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+    cases := map[int]time.Duration{
+        0:  time.Second,
+        1:  2 * time.Second,
+        3:  8 * time.Second,
+        5:  30 * time.Second,
+        40: 30 * time.Second,
+    }
+    for attempt, want := range cases {
+        if got := reconnectDelay(attempt); got != want {
+            t.Errorf("attempt %d: got %s, want %s", attempt, got, want)
+        }
+    }
+}
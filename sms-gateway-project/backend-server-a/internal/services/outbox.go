@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sms-gateway/backend-server-a/internal/models"
+	"sms-gateway/shared/contract"
+)
+
+const (
+	outboxPendingKey  = "outbox:pending"
+	outboxInflightKey = "outbox:inflight"
+)
+
+// Outbox records accepted messages in Redis until the relay has published
+// them. Entries move from the pending list to the in-flight list while they
+// are being published and are removed once the broker confirms them, so a
+// crash at any point leaves every accepted message in one of the two lists.
+type Outbox struct {
+	rdb *redis.Client
+}
+
+// NewOutbox creates a new Outbox.
+func NewOutbox(rdb *redis.Client) *Outbox {
+	return &Outbox{rdb: rdb}
+}
+
+// Add queues payloads for publishing in a single command. Passing a
+// transaction pipeline as cmd lets the caller store other state atomically
+// with the entries.
+func (o *Outbox) Add(ctx context.Context, cmd redis.Cmdable, payloads ...models.MessagePayload) error {
+	entries := make([]interface{}, 0, len(payloads))
+	for _, payload := range payloads {
+		body, err := contract.Encode(payload)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, body)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return cmd.LPush(ctx, outboxPendingKey, entries...).Err()
+}
+
+// Claim moves the oldest pending entry to the in-flight list and returns it,
+// waiting up to timeout for one to arrive; a timeout of zero does not wait.
+// It returns redis.Nil when the outbox stayed empty.
+func (o *Outbox) Claim(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		return o.rdb.LMove(ctx, outboxPendingKey, outboxInflightKey, "RIGHT", "LEFT").Bytes()
+	}
+	return o.rdb.BLMove(ctx, outboxPendingKey, outboxInflightKey, "RIGHT", "LEFT", timeout).Bytes()
+}
+
+// Ack removes a published entry from the outbox.
+func (o *Outbox) Ack(ctx context.Context, entry []byte) error {
+	return o.rdb.LRem(ctx, outboxInflightKey, 1, entry).Err()
+}
+
+// Release returns an entry that could not be published to the front of the
+// pending list so it is retried first.
+func (o *Outbox) Release(ctx context.Context, entry []byte) error {
+	_, err := o.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, outboxInflightKey, 1, entry)
+		pipe.RPush(ctx, outboxPendingKey, entry)
+		return nil
+	})
+	return err
+}
+
+// Recover moves entries left in flight by a previous run back to pending.
+func (o *Outbox) Recover(ctx context.Context) error {
+	for {
+		err := o.rdb.LMove(ctx, outboxInflightKey, outboxPendingKey, "RIGHT", "RIGHT").Err()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
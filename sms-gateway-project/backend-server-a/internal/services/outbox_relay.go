@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sms-gateway/backend-server-a/internal/models"
+	"sms-gateway/shared/contract"
+)
+
+// OutboxStore is the queue of accepted messages the relay drains.
+type OutboxStore interface {
+	Claim(ctx context.Context, timeout time.Duration) ([]byte, error)
+	Ack(ctx context.Context, entry []byte) error
+	Release(ctx context.Context, entry []byte) error
+	Recover(ctx context.Context) error
+}
+
+// Publisher publishes messages and returns once the broker has confirmed
+// them, with one error per message.
+type Publisher interface {
+	PublishBatch(ctx context.Context, payloads []models.MessagePayload) []error
+}
+
+const (
+	// relayBatchSize caps the entries published before waiting for confirms.
+	relayBatchSize   = 100
+	relayPollTimeout = 5 * time.Second
+	relayMinBackoff  = time.Second
+	relayMaxBackoff  = 30 * time.Second
+)
+
+// OutboxRelay publishes outbox entries to RabbitMQ. It claims whatever is
+// pending, up to relayBatchSize entries, and publishes them together, so a
+// bulk request is drained in a few round-trips. An entry is removed from the
+// outbox only after the broker confirmed it, so every accepted message is
+// published at least once.
+type OutboxRelay struct {
+	Store     OutboxStore
+	Publisher Publisher
+
+	sleep func(context.Context, time.Duration)
+}
+
+// NewOutboxRelay creates a new OutboxRelay.
+func NewOutboxRelay(store OutboxStore, publisher Publisher) *OutboxRelay {
+	return &OutboxRelay{Store: store, Publisher: publisher, sleep: sleepContext}
+}
+
+// Run relays entries until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	if err := r.Store.Recover(ctx); err != nil {
+		log.Printf("outbox relay: recover: %v", err)
+	}
+	backoff := relayMinBackoff
+	for ctx.Err() == nil {
+		ok, err := r.relayBatch(ctx, relayPollTimeout)
+		if err != nil {
+			log.Printf("outbox relay: %v", err)
+			r.sleep(ctx, backoff)
+			backoff = min(backoff*2, relayMaxBackoff)
+			continue
+		}
+		if ok {
+			backoff = relayMinBackoff
+		}
+	}
+}
+
+// Flush publishes pending entries until the outbox is empty, a publish fails
+// or ctx is done. It is used on shutdown once Run has returned; whatever is
+// left stays in the outbox for the next start.
+func (r *OutboxRelay) Flush(ctx context.Context) error {
+	if err := r.Store.Recover(ctx); err != nil {
+		return err
+	}
+	for {
+		ok, err := r.relayBatch(ctx, 0)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ctx.Err()
+		}
+	}
+}
+
+// relayBatch claims the next entries, waiting up to timeout for the first,
+// publishes them and settles each one. Entries the broker confirmed are
+// removed; the others are put back in order and the first publish error is
+// returned. It reports whether there were entries to handle.
+func (r *OutboxRelay) relayBatch(ctx context.Context, timeout time.Duration) (bool, error) {
+	entries, err := r.claim(ctx, timeout)
+	if len(entries) == 0 {
+		return false, err
+	}
+	if ctx.Err() != nil {
+		// claimed while stopping; leave them for Flush or the next start
+		return false, r.release(context.WithoutCancel(ctx), entries)
+	}
+
+	var valid [][]byte
+	var payloads []models.MessagePayload
+	for _, entry := range entries {
+		payload, err := contract.Decode(entry)
+		if err != nil {
+			// retrying cannot fix an entry that does not match the contract
+			log.Printf("outbox relay: dropping invalid entry %q: %v", payload.TrackingID, err)
+			if err := r.Store.Ack(ctx, entry); err != nil {
+				return true, err
+			}
+			continue
+		}
+		valid = append(valid, entry)
+		payloads = append(payloads, payload)
+	}
+	if len(payloads) == 0 {
+		return true, nil
+	}
+
+	var failed [][]byte
+	var firstErr error
+	for i, err := range r.Publisher.PublishBatch(ctx, payloads) {
+		if err != nil {
+			failed = append(failed, valid[i])
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := r.Store.Ack(ctx, valid[i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := r.release(context.WithoutCancel(ctx), failed); err != nil {
+		log.Printf("outbox relay: release: %v", err)
+	}
+	return len(failed) < len(payloads), firstErr
+}
+
+// claim takes up to relayBatchSize entries, waiting up to timeout for the
+// first one only. Entries claimed before an error are returned with it.
+func (r *OutboxRelay) claim(ctx context.Context, timeout time.Duration) ([][]byte, error) {
+	var entries [][]byte
+	for len(entries) < relayBatchSize {
+		entry, err := r.Store.Claim(ctx, timeout)
+		if errors.Is(err, redis.Nil) {
+			return entries, nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return entries, nil
+			}
+			return entries, err
+		}
+		entries = append(entries, entry)
+		timeout = 0
+	}
+	return entries, nil
+}
+
+// release puts entries back at the front of the outbox, keeping their order.
+func (r *OutboxRelay) release(ctx context.Context, entries [][]byte) error {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := r.Store.Release(ctx, entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+
+    "sms-gateway/backend-server-a/internal/models"
+    "sms-gateway/shared/contract"
+)
+
+type fakeOutbox struct {
+    pending  [][]byte
+    inflight [][]byte
+    acked    [][]byte
+}
+
+func (f *fakeOutbox) Claim(ctx context.Context, timeout time.Duration) ([]byte, error) {
+    if len(f.pending) == 0 {
+        return nil, redis.Nil
+    }
+    entry := f.pending[0]
+    f.pending = f.pending[1:]
+    f.inflight = append(f.inflight, entry)
+    return entry, nil
+}
+
+func (f *fakeOutbox) remove(entry []byte) {
+    for i, e := range f.inflight {
+        if string(e) == string(entry) {
+            f.inflight = append(f.inflight[:i], f.inflight[i+1:]...)
+            return
+        }
+    }
+}
+
+func (f *fakeOutbox) Ack(ctx context.Context, entry []byte) error {
+    f.remove(entry)
+    f.acked = append(f.acked, entry)
+    return nil
+}
+
+func (f *fakeOutbox) Release(ctx context.Context, entry []byte) error {
+    f.remove(entry)
+    f.pending = append([][]byte{entry}, f.pending...)
+    return nil
+}
+
+func (f *fakeOutbox) Recover(ctx context.Context) error {
+    f.pending = append(f.inflight, f.pending...)
+    f.inflight = nil
+    return nil
+}
+
+// fakePublisher fails the payloads matching its queued errors, in order.
+type fakePublisher struct {
+    errs      []error
+    batches   int
+    published []models.MessagePayload
+}
+
+func (f *fakePublisher) PublishBatch(ctx context.Context, payloads []models.MessagePayload) []error {
+    f.batches++
+    errs := make([]error, len(payloads))
+    for i, payload := range payloads {
+        if len(f.errs) > 0 {
+            errs[i] = f.errs[0]
+            f.errs = f.errs[1:]
+            if errs[i] != nil {
+                continue
+            }
+        }
+        f.published = append(f.published, payload)
+    }
+    return errs
+}
+
+func outboxEntry(t *testing.T, trackingID string) []byte {
+    t.Helper()
+    body, err := contract.Encode(models.MessagePayload{TrackingID: trackingID, Recipient: "0912", Text: "hi"})
+    if err != nil {
+        t.Fatalf("encode: %v", err)
+    }
+    return body
+}
+
+func TestRelayBatchPublishesAndAcks(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1")}}
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(store, pub)
+
+    ok, err := relay.relayBatch(context.Background(), relayPollTimeout)
+    if err != nil || !ok {
+        t.Fatalf("relayBatch = %v, %v", ok, err)
+    }
+    if len(pub.published) != 1 || pub.published[0].TrackingID != "t1" {
+        t.Errorf("published = %+v", pub.published)
+    }
+    if len(store.acked) != 1 || len(store.inflight) != 0 {
+        t.Errorf("store = %+v", store)
+    }
+}
+
+func TestRelayBatchReleasesOnPublishFailure(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1"), outboxEntry(t, "t2")}}
+    pub := &fakePublisher{errs: []error{errors.New("broker down"), errors.New("broker down")}}
+    relay := NewOutboxRelay(store, pub)
+
+    if _, err := relay.relayBatch(context.Background(), relayPollTimeout); err == nil {
+        t.Fatal("expected publish error")
+    }
+    if len(store.pending) != 2 || len(store.inflight) != 0 || len(store.acked) != 0 {
+        t.Fatalf("store = %+v", store)
+    }
+
+    // the released entry is retried before newer ones
+    if ok, err := relay.relayBatch(context.Background(), relayPollTimeout); err != nil || !ok {
+        t.Fatalf("relayBatch = %v, %v", ok, err)
+    }
+    if pub.published[0].TrackingID != "t1" {
+        t.Errorf("published = %+v", pub.published)
+    }
+}
+
+func TestRelayBatchDropsInvalidEntry(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{[]byte(`{"tracking_id":"t1"}`)}}
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(store, pub)
+
+    if _, err := relay.relayBatch(context.Background(), relayPollTimeout); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(pub.published) != 0 || len(store.acked) != 1 {
+        t.Errorf("published = %d, acked = %d", len(pub.published), len(store.acked))
+    }
+}
+
+func TestRunRecoversInflightEntries(t *testing.T) {
+    store := &fakeOutbox{inflight: [][]byte{outboxEntry(t, "t1")}}
+    ctx, cancel := context.WithCancel(context.Background())
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(&cancelWhenEmpty{fakeOutbox: store, cancel: cancel}, pub)
+
+    relay.Run(ctx)
+    if len(pub.published) != 1 || pub.published[0].TrackingID != "t1" {
+        t.Fatalf("published = %+v", pub.published)
+    }
+}
+
+// cancelWhenEmpty stops the relay once a poll finds the outbox drained.
+type cancelWhenEmpty struct {
+    *fakeOutbox
+    cancel context.CancelFunc
+}
+
+func (c *cancelWhenEmpty) Claim(ctx context.Context, timeout time.Duration) ([]byte, error) {
+    entry, err := c.fakeOutbox.Claim(ctx, timeout)
+    if errors.Is(err, redis.Nil) && timeout > 0 {
+        c.cancel()
+    }
+    return entry, err
+}
+
+func TestFlushDrainsOutbox(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1"), []byte(`{}`), outboxEntry(t, "t2")}}
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(store, pub)
+
+    if err := relay.Flush(context.Background()); err != nil {
+        t.Fatalf("flush: %v", err)
+    }
+    if len(pub.published) != 2 || len(store.pending) != 0 || len(store.acked) != 3 {
+        t.Fatalf("published = %d, pending = %d, acked = %d", len(pub.published), len(store.pending), len(store.acked))
+    }
+}
+
+func TestFlushStopsOnPublishFailure(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1"), outboxEntry(t, "t2")}}
+    pub := &fakePublisher{errs: []error{errors.New("broker down"), errors.New("broker down")}}
+    relay := NewOutboxRelay(store, pub)
+
+    if err := relay.Flush(context.Background()); err == nil {
+        t.Fatal("expected publish error")
+    }
+    if len(store.pending) != 2 {
+        t.Fatalf("entries must stay in the outbox, pending = %d", len(store.pending))
+    }
+}
+
+func TestRelayBatchReleasesEntryClaimedWhileStopping(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1")}}
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(store, pub)
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    if ok, err := relay.relayBatch(ctx, relayPollTimeout); ok || err != nil {
+        t.Fatalf("relayBatch = %v, %v", ok, err)
+    }
+    if len(pub.published) != 0 || len(store.pending) != 1 || len(store.inflight) != 0 {
+        t.Fatalf("store = %+v", store)
+    }
+}
+
+func TestRelayBatchPublishesPendingEntriesTogether(t *testing.T) {
+    store := &fakeOutbox{pending: [][]byte{outboxEntry(t, "t1"), outboxEntry(t, "t2"), outboxEntry(t, "t3"), outboxEntry(t, "t4")}}
+    pub := &fakePublisher{errs: []error{nil, errors.New("nacked"), nil, errors.New("nacked")}}
+    relay := NewOutboxRelay(store, pub)
+
+    ok, err := relay.relayBatch(context.Background(), relayPollTimeout)
+    if err == nil || !ok {
+        t.Fatalf("relayBatch = %v, %v", ok, err)
+    }
+    if pub.batches != 1 || len(pub.published) != 2 || len(store.acked) != 2 {
+        t.Fatalf("batches = %d, published = %d, acked = %d", pub.batches, len(pub.published), len(store.acked))
+    }
+    // failed entries go back in their original order, ahead of newer ones
+    if len(store.pending) != 2 || string(store.pending[0]) != string(outboxEntry(t, "t2")) || string(store.pending[1]) != string(outboxEntry(t, "t4")) {
+        t.Fatalf("pending = %q", store.pending)
+    }
+    if len(store.inflight) != 0 {
+        t.Fatalf("inflight = %q", store.inflight)
+    }
+}
+
+func TestRelayBatchCapsBatchSize(t *testing.T) {
+    store := &fakeOutbox{}
+    for i := 0; i < relayBatchSize+1; i++ {
+        store.pending = append(store.pending, outboxEntry(t, fmt.Sprintf("t%d", i)))
+    }
+    pub := &fakePublisher{}
+    relay := NewOutboxRelay(store, pub)
+
+    if ok, err := relay.relayBatch(context.Background(), relayPollTimeout); err != nil || !ok {
+        t.Fatalf("relayBatch = %v, %v", ok, err)
+    }
+    if len(pub.published) != relayBatchSize || len(store.pending) != 1 {
+        t.Fatalf("published = %d, pending = %d", len(pub.published), len(store.pending))
+    }
+}
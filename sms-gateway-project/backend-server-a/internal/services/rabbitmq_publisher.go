@@ -2,53 +2,181 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 
 	"sms-gateway/backend-server-a/internal/models"
+	"sms-gateway/shared/contract"
 )
 
+// DefaultChannelPoolSize is the number of idle channels a publisher keeps open.
+const DefaultChannelPoolSize = 8
+
+// RabbitMQPublisher publishes messages in confirm mode. It survives broker
+// restarts by reconnecting in the background and is safe for concurrent use.
 type RabbitMQPublisher struct {
-	conn      *amqp.Connection
-	ch        *amqp.Channel
+	conns     *ConnectionManager
+	pool      *channelPool
 	queueName string
 }
 
 func NewPublisher(url, queue string) (*RabbitMQPublisher, error) {
-	conn, err := amqp.Dial(url)
+	p := &RabbitMQPublisher{queueName: queue}
+	conns, err := NewConnectionManager(url, p.declare)
+	if err != nil {
+		return nil, err
+	}
+	p.conns = conns
+	p.pool = newChannelPool(DefaultChannelPoolSize, p.openChannel)
+	return p, nil
+}
+
+// declare declares the queue on a new connection. The broker refuses to
+// redeclare a queue with different arguments, so a queue created before
+// dead-lettering was added is replaced when it is empty; a non-empty one has
+// to be drained first.
+func (p *RabbitMQPublisher) declare(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	_, err = ch.QueueDeclare(p.queueName, true, false, false, false, p.queueArgs())
+	var aerr *amqp.Error
+	if !errors.As(err, &aerr) || aerr.Code != amqp.PreconditionFailed {
+		return err
+	}
+
+	// the refused declaration closed the channel
+	ch, err = conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if _, err := ch.QueueDelete(p.queueName, false, true, false); err != nil {
+		return fmt.Errorf("queue %s exists with other arguments and is not empty; drain and delete it, then restart: %w", p.queueName, err)
+	}
+	log.Printf("rabbitmq: redeclared queue %s with dead-letter arguments", p.queueName)
+	_, err = ch.QueueDeclare(p.queueName, true, false, false, false, p.queueArgs())
+	return err
+}
+
+// queueArgs must match the arguments the worker declares the queue with.
+func (p *RabbitMQPublisher) queueArgs() amqp.Table {
+	return amqp.Table{
+		"x-dead-letter-exchange":    p.queueName + ".dlx",
+		"x-dead-letter-routing-key": p.queueName,
+	}
+}
+
+// openChannel opens a confirm-mode channel on the current connection.
+func (p *RabbitMQPublisher) openChannel() (confirmChannel, error) {
+	conn, err := p.conns.Connection()
 	if err != nil {
 		return nil, err
 	}
 	ch, err := conn.Channel()
 	if err != nil {
-		conn.Close()
 		return nil, err
 	}
-	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+	if err := ch.Confirm(false); err != nil {
 		ch.Close()
-		conn.Close()
 		return nil, err
 	}
-	return &RabbitMQPublisher{conn: conn, ch: ch, queueName: queue}, nil
+	return ch, nil
 }
 
+// Publish publishes payload and waits until the broker confirms it. While the
+// connection is down it fails fast with ErrNotConnected.
 func (p *RabbitMQPublisher) Publish(ctx context.Context, payload models.MessagePayload) error {
-	body, err := json.Marshal(payload)
+	return p.PublishBatch(ctx, []models.MessagePayload{payload})[0]
+}
+
+// PublishBatch publishes payloads on one channel and then waits for all their
+// confirmations, so a batch costs a single broker round-trip instead of one
+// per message. It returns one error per payload, nil for those the broker
+// confirmed.
+func (p *RabbitMQPublisher) PublishBatch(ctx context.Context, payloads []models.MessagePayload) []error {
+	errs := make([]error, len(payloads))
+	ch, err := p.pool.get()
 	if err != nil {
-		return err
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	// a channel that failed mid-batch is closed rather than handed out again
+	healthy := true
+	confirms := make([]*amqp.DeferredConfirmation, len(payloads))
+	for i, payload := range payloads {
+		msg, err := newPublishing(payload)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if !healthy {
+			errs[i] = errors.New("batch aborted")
+			continue
+		}
+		confirms[i], err = ch.PublishWithDeferredConfirmWithContext(ctx, "", p.queueName, false, false, msg)
+		if err != nil {
+			errs[i] = err
+			healthy = false
+		}
 	}
-	return p.ch.PublishWithContext(ctx, "", p.queueName, false, false, amqp.Publishing{
-		ContentType: "application/json",
-		Body:        body,
-	})
+	for i, confirm := range confirms {
+		if confirm == nil {
+			continue
+		}
+		acked, err := confirm.WaitContext(ctx)
+		switch {
+		case err != nil:
+			// the confirmation may still arrive; don't hand the channel out again
+			errs[i] = err
+			healthy = false
+		case !acked:
+			errs[i] = errors.New("message nacked by broker")
+		}
+	}
+	if healthy {
+		p.pool.put(ch)
+	} else {
+		ch.Close()
+	}
+	return errs
 }
 
-func (p *RabbitMQPublisher) Close() {
-	if p.ch != nil {
-		p.ch.Close()
+// newPublishing builds the AMQP message for payload. Messages with a TTL
+// expire in the queue once the TTL has passed. A scheduled message's TTL
+// counts from its send time, so it expires at its ExpiresAt instead.
+func newPublishing(payload models.MessagePayload) (amqp.Publishing, error) {
+	body, err := contract.Encode(payload)
+	if err != nil {
+		return amqp.Publishing{}, err
 	}
-	if p.conn != nil {
-		p.conn.Close()
+	msg := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
 	}
+	switch {
+	case payload.SendAt != nil && payload.ExpiresAt != nil:
+		if ms := time.Until(*payload.ExpiresAt).Milliseconds(); ms > 0 {
+			msg.Expiration = strconv.FormatInt(ms, 10)
+		}
+	case payload.SendAt == nil && payload.TTL > 0:
+		msg.Expiration = strconv.Itoa(payload.TTL * 1000)
+	}
+	return msg, nil
+}
+
+func (p *RabbitMQPublisher) Close() {
+	p.pool.close()
+	p.conns.Close()
 }
@@ -0,0 +1,84 @@
+package services
+
+import (
+    "errors"
+    "strconv"
+    "testing"
+    "time"
+
+    "sms-gateway/backend-server-a/internal/models"
+    "sms-gateway/shared/contract"
+)
+
+func TestNewPublishingSetsExpiration(t *testing.T) {
+    expiresAt := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+    msg, err := newPublishing(models.MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", TTL: 30, ExpiresAt: &expiresAt})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if msg.Expiration != "30000" {
+        t.Errorf("Expiration = %s", msg.Expiration)
+    }
+
+    decoded, err := contract.Decode(msg.Body)
+    if err != nil {
+        t.Fatalf("decode: %v", err)
+    }
+    if decoded.ExpiresAt == nil || !decoded.ExpiresAt.Equal(expiresAt) {
+        t.Errorf("ExpiresAt = %v", decoded.ExpiresAt)
+    }
+}
+
+func TestNewPublishingWithoutTTL(t *testing.T) {
+    msg, err := newPublishing(models.MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if msg.Expiration != "" {
+        t.Errorf("Expiration = %s", msg.Expiration)
+    }
+}
+
+func TestNewPublishingScheduledExpiresAfterSendAt(t *testing.T) {
+    sendAt := time.Now().Add(time.Hour)
+    expiresAt := sendAt.Add(30 * time.Second)
+    msg, err := newPublishing(models.MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", TTL: 30, SendAt: &sendAt, ExpiresAt: &expiresAt})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    ms, err := strconv.ParseInt(msg.Expiration, 10, 64)
+    if err != nil {
+        t.Fatalf("Expiration = %q", msg.Expiration)
+    }
+    if ms <= time.Hour.Milliseconds() || ms > (time.Hour+30*time.Second).Milliseconds() {
+        t.Errorf("Expiration = %d, want it to reach past the send time", ms)
+    }
+
+    msg, err = newPublishing(models.MessagePayload{TrackingID: "t2", Recipient: "0912", Text: "hi", SendAt: &sendAt})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if msg.Expiration != "" {
+        t.Errorf("Expiration = %s", msg.Expiration)
+    }
+}
+
+func TestNewPublishingMatchesContract(t *testing.T) {
+    msg, err := newPublishing(models.MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hello", Providers: []string{"magfa"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    decoded, err := contract.Decode(msg.Body)
+    if err != nil {
+        t.Fatalf("decode: %v", err)
+    }
+    if decoded.SchemaVersion != contract.SchemaVersion || decoded.Text != "hello" || decoded.Recipient != "0912" {
+        t.Errorf("decoded = %+v", decoded)
+    }
+}
+
+func TestNewPublishingRejectsInvalidPayload(t *testing.T) {
+    if _, err := newPublishing(models.MessagePayload{TrackingID: "t1"}); !errors.Is(err, contract.ErrInvalidPayload) {
+        t.Errorf("err = %v", err)
+    }
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"sms-gateway/shared/template"
+)
+
+// DefaultTemplateCacheTTL is how long a fetched template is reused before it
+// is looked up again, so edits in server B take effect within this time.
+const DefaultTemplateCacheTTL = time.Minute
+
+// ErrTemplateNotFound is returned when server B does not know a template.
+var ErrTemplateNotFound = errors.New("template not found")
+
+type cachedTemplate struct {
+	tpl     template.Template
+	expires time.Time
+}
+
+// TemplateClient fetches message templates from server B's internal API and
+// caches them for DefaultTemplateCacheTTL.
+type TemplateClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedTemplate
+}
+
+// NewTemplateClient creates a TemplateClient for the server B at baseURL.
+func NewTemplateClient(baseURL, apiKey string) *TemplateClient {
+	return &TemplateClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		ttl:     DefaultTemplateCacheTTL,
+		now:     time.Now,
+		cache:   map[string]cachedTemplate{},
+	}
+}
+
+// Get returns the template with the given ID.
+func (c *TemplateClient) Get(ctx context.Context, id string) (template.Template, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.tpl, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/internal/templates/"+url.PathEscape(id), nil)
+	if err != nil {
+		return template.Template{}, err
+	}
+	req.Header.Set("X-Internal-API-Key", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return template.Template{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return template.Template{}, ErrTemplateNotFound
+	case resp.StatusCode != http.StatusOK:
+		return template.Template{}, fmt.Errorf("template lookup: unexpected status %d", resp.StatusCode)
+	}
+	var tpl template.Template
+	if err := json.NewDecoder(resp.Body).Decode(&tpl); err != nil {
+		return template.Template{}, fmt.Errorf("template lookup: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cache[id] = cachedTemplate{tpl: tpl, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return tpl, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestTemplateClientGet(t *testing.T) {
+    calls := 0
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        if r.Header.Get("X-Internal-API-Key") != "key" {
+            w.WriteHeader(http.StatusUnauthorized)
+            return
+        }
+        switch r.URL.Path {
+        case "/internal/templates/otp":
+            w.Write([]byte(`{"id":"otp","default_language":"en","variants":[{"language":"en","body":"Code {{code}}"}]}`))
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }))
+    defer srv.Close()
+
+    client := NewTemplateClient(srv.URL+"/", "key")
+    now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+    client.now = func() time.Time { return now }
+
+    tpl, err := client.Get(context.Background(), "otp")
+    if err != nil {
+        t.Fatalf("get: %v", err)
+    }
+    if tpl.ID != "otp" || len(tpl.Variants) != 1 || tpl.Variants[0].Body != "Code {{code}}" {
+        t.Fatalf("unexpected template: %+v", tpl)
+    }
+
+    // served from the cache until it expires
+    client.Get(context.Background(), "otp")
+    if calls != 1 {
+        t.Fatalf("expected a cached template, got %d calls", calls)
+    }
+    now = now.Add(DefaultTemplateCacheTTL)
+    client.Get(context.Background(), "otp")
+    if calls != 2 {
+        t.Fatalf("expected a refetch after expiry, got %d calls", calls)
+    }
+
+    if _, err := client.Get(context.Background(), "missing"); !errors.Is(err, ErrTemplateNotFound) {
+        t.Fatalf("expected ErrTemplateNotFound, got %v", err)
+    }
+    if _, err := NewTemplateClient(srv.URL, "wrong").Get(context.Background(), "otp"); err == nil || errors.Is(err, ErrTemplateNotFound) {
+        t.Fatalf("expected an error for a rejected key, got %v", err)
+    }
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,6 +20,10 @@ import (
 	"time"
 )
 
+// shutdownTimeout bounds how long in-flight requests and messages may take to
+// finish after a termination signal.
+const shutdownTimeout = 20 * time.Second
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -31,6 +40,12 @@ func main() {
 
 	msgRepo := repository.NewMessageRepository(db)
 	userRepo := repository.NewUserRepository(db)
+	providerRepo := repository.NewProviderRepository(db)
+	deadLetterRepo := repository.NewDeadLetterRepository(db)
+	batchRepo := repository.NewBatchRepository(db)
+	templateRepo := repository.NewTemplateRepository(db)
+	blocklistRepo := repository.NewBlocklistRepository(db)
+	inboundRepo := repository.NewInboundRepository(db)
 
 	if err := services.SeedAdminUser(userRepo, cfg.DefaultAdminUsername, cfg.DefaultAdminPassword); err != nil {
 		log.Fatalf("seed admin: %v", err)
@@ -45,13 +60,34 @@ func main() {
 		}
 	}
 
-	engine := services.NewPolicyEngine(msgRepo, provs)
-	consumer := worker.NewConsumer(cfg.RabbitMQURL, cfg.RabbitMQQueueName, engine)
-	if err := consumer.StartConsumer(); err != nil {
+	// ctx stops the background workers; it is cancelled during shutdown
+	// after the consumer has drained.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	engine := services.NewPolicyEngine(msgRepo, provs, cfg.Providers)
+	engine.Batches = batchRepo
+	engine.Country = cfg.DefaultCountry
+	engine.Blocklist = blocklistRepo
+	registry := services.NewProviderRegistry(providerRepo, engine, cfg.ProviderKMSKey, cfg.ProviderRefreshInterval)
+	registry.Start(ctx)
+
+	consumer := worker.NewConsumer(cfg.RabbitMQURL, cfg.RabbitMQQueueName, engine, deadLetterRepo, cfg.MaxDeliveryAttempts, cfg.RetryDelays, cfg.WorkerConcurrency)
+	if err := consumer.StartConsumer(ctx); err != nil {
 		log.Fatalf("consumer: %v", err)
 	}
+	services.NewScheduler(msgRepo, consumer, cfg.SchedulerInterval).Start(ctx)
 
 	handlers := api.NewHandlers(msgRepo, userRepo, jwtSvc)
+	deadLetterHandlers := api.NewDeadLetterHandlers(deadLetterRepo, consumer)
+	batchHandlers := api.NewBatchHandlers(batchRepo)
+	scheduledHandlers := api.NewScheduledHandlers(msgRepo)
+	templateHandlers := api.NewTemplateHandlers(templateRepo)
+	blocklistHandlers := api.NewBlocklistHandlers(blocklistRepo, cfg.DefaultCountry)
+	inboundHandlers := api.NewInboundHandlers(inboundRepo, blocklistRepo, cfg.OptOutKeywords, cfg.DefaultCountry)
+	deliveryHandlers := api.NewDeliveryHandlers(msgRepo, engine)
 	r := gin.Default()
 
 	// Configure CORS middleware
@@ -64,6 +100,8 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	r.GET("/health", api.HealthHandler(consumer))
+
 	authRoutes := r.Group("/api/auth")
 	authRoutes.POST("/login", handlers.LoginHandler)
 	authRoutes.POST("/logout", handlers.LogoutHandler)
@@ -73,7 +111,13 @@ func main() {
 	apiRoutes.GET("/dashboard", handlers.GetDashboardStatsHandler)
 	apiRoutes.GET("/messages", handlers.GetMessagesHandler)
 	apiRoutes.GET("/status/:tracking_id", handlers.GetStatusHandler)
-	apiRoutes.POST("/webhooks/delivery-report/:provider", handlers.DeliveryWebhookHandler)
+	apiRoutes.GET("/batches/:id", batchHandlers.GetBatchHandler)
+	apiRoutes.POST("/batches/:id/cancel", batchHandlers.CancelBatchHandler)
+	apiRoutes.GET("/inbound", inboundHandlers.ListInboundHandler)
+	apiRoutes.GET("/scheduled", scheduledHandlers.ListScheduledHandler)
+	apiRoutes.DELETE("/scheduled/:tracking_id", scheduledHandlers.CancelScheduledHandler)
+	// deprecated JWT-authenticated alias of /webhooks/delivery-report/:provider
+	apiRoutes.POST("/webhooks/delivery-report/:provider", deliveryHandlers.DeliveryWebhookHandler)
 
 	userRoutes := apiRoutes.Group("/users")
 	userRoutes.Use(api.AdminOnlyMiddleware())
@@ -84,7 +128,58 @@ func main() {
 	userRoutes.POST(":id/activate", handlers.ActivateUserHandler)
 	userRoutes.POST(":id/deactivate", handlers.DeactivateUserHandler)
 
-	if err := r.Run(cfg.ListenAddr); err != nil {
-		log.Fatalf("server: %v", err)
+	dlqRoutes := apiRoutes.Group("/dlq")
+	dlqRoutes.Use(api.AdminOnlyMiddleware())
+	dlqRoutes.GET("", deadLetterHandlers.ListDeadLettersHandler)
+	dlqRoutes.DELETE("", deadLetterHandlers.PurgeDeadLettersHandler)
+	dlqRoutes.GET(":id", deadLetterHandlers.GetDeadLetterHandler)
+	dlqRoutes.DELETE(":id", deadLetterHandlers.DeleteDeadLetterHandler)
+	dlqRoutes.POST(":id/replay", deadLetterHandlers.ReplayDeadLetterHandler)
+
+	templateRoutes := apiRoutes.Group("/templates")
+	templateRoutes.GET("", templateHandlers.ListTemplatesHandler)
+	templateRoutes.GET(":id", templateHandlers.GetTemplateHandler)
+	templateRoutes.POST("", api.AdminOnlyMiddleware(), templateHandlers.CreateTemplateHandler)
+	templateRoutes.PUT(":id", api.AdminOnlyMiddleware(), templateHandlers.UpdateTemplateHandler)
+	templateRoutes.DELETE(":id", api.AdminOnlyMiddleware(), templateHandlers.DeleteTemplateHandler)
+
+	blocklistRoutes := apiRoutes.Group("/blocklist")
+	blocklistRoutes.GET("", blocklistHandlers.ListBlocklistHandler)
+	blocklistRoutes.POST("", api.AdminOnlyMiddleware(), blocklistHandlers.CreateBlocklistHandler)
+	blocklistRoutes.POST("/import", api.AdminOnlyMiddleware(), blocklistHandlers.ImportBlocklistHandler)
+	blocklistRoutes.DELETE(":id", api.AdminOnlyMiddleware(), blocklistHandlers.DeleteBlocklistHandler)
+
+	// provider callbacks, authenticated with the webhook secret instead of a JWT
+	webhookRoutes := r.Group("/webhooks")
+	webhookRoutes.Use(api.WebhookAuthMiddleware(cfg.WebhookSecret))
+	webhookRoutes.POST("/inbound/:provider", inboundHandlers.InboundWebhookHandler)
+	webhookRoutes.POST("/delivery-report/:provider", deliveryHandlers.DeliveryWebhookHandler)
+
+	// endpoints used by server A, authenticated with the shared internal key
+	internalRoutes := r.Group("/internal")
+	internalRoutes.Use(api.InternalAuthMiddleware(cfg.InternalAPIKey))
+	internalRoutes.GET("/templates/:id", templateHandlers.InternalTemplateHandler)
+
+	srv := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server: %v", err)
+		}
+	}()
+
+	<-sigCtx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	if err := consumer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("consumer shutdown: %v", err)
+	}
+	cancel()
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
 	}
 }
@@ -12,4 +12,7 @@ require (
 	gorm.io/driver/postgres v1.5.5
 	gorm.io/driver/sqlite v1.5.5
 	gorm.io/gorm v1.25.7
+	sms-gateway/shared v0.0.0
 )
+
+replace sms-gateway/shared => ../shared
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// finalStatuses are the message statuses that no longer change on their own.
+// FAILED is not one of them: it is also the status of a message waiting in a
+// retry tier.
+var finalStatuses = []string{"SENT", "DELIVERED", "UNDELIVERED", "EXPIRED", "REJECTED", "CANCELLED", "BLOCKED"}
+
+// BatchHandlers serves the batch status API.
+type BatchHandlers struct {
+	Repo *repository.BatchRepository
+}
+
+// NewBatchHandlers creates a new BatchHandlers instance.
+func NewBatchHandlers(repo *repository.BatchRepository) *BatchHandlers {
+	return &BatchHandlers{Repo: repo}
+}
+
+// BatchStatus is the aggregate state of a batch. Pending counts messages that
+// have not reached a final status, including those not consumed yet.
+type BatchStatus struct {
+	ID        string           `json:"id"`
+	Status    string           `json:"status"`
+	Size      int              `json:"size"`
+	Counts    map[string]int64 `json:"counts"`
+	Pending   int64            `json:"pending"`
+	Progress  float64          `json:"progress"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// GetBatchHandler reports per-status counts and the progress of a batch.
+func (h *BatchHandlers) GetBatchHandler(c *gin.Context) {
+	status, ok := h.batchStatus(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CancelBatchHandler cancels the messages of a batch that were not sent yet.
+func (h *BatchHandlers) CancelBatchHandler(c *gin.Context) {
+	if _, err := h.Repo.GetBatch(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if _, err := h.Repo.CancelBatch(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not cancel batch"})
+		return
+	}
+	status, ok := h.batchStatus(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// batchStatus loads the status of the batch in the request path, writing an
+// error response when that fails.
+func (h *BatchHandlers) batchStatus(c *gin.Context) (BatchStatus, bool) {
+	batch, err := h.Repo.GetBatch(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return BatchStatus{}, false
+	}
+	counts, err := h.Repo.CountByStatus(batch.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not count messages"})
+		return BatchStatus{}, false
+	}
+
+	var done int64
+	for _, s := range finalStatuses {
+		done += counts[s]
+	}
+	status := BatchStatus{
+		ID:        batch.ID,
+		Status:    batch.Status,
+		Size:      batch.Size,
+		Counts:    counts,
+		Pending:   max(int64(batch.Size)-done, 0),
+		CreatedAt: batch.CreatedAt,
+	}
+	if batch.Size > 0 {
+		status.Progress = min(float64(done)/float64(batch.Size), 1)
+	}
+	return status, true
+}
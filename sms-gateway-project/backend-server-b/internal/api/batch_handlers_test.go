@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+func TestBatchHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Batch{}, &models.Message{}, &models.MessageEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := repository.NewBatchRepository(db)
+	if err := repo.EnsureBatch("b1", 4); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	msgs := []models.Message{
+		{TrackingID: "t1", BatchID: "b1", Status: "SENT"},
+		{TrackingID: "t2", BatchID: "b1", Status: "FAILED"},
+		{TrackingID: "t3", BatchID: "b1", Status: "QUEUED"},
+		{TrackingID: "t4", Status: "QUEUED"},
+	}
+	if err := db.Create(&msgs).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+
+	h := NewBatchHandlers(repo)
+	r := gin.New()
+	r.GET("/batches/:id", h.GetBatchHandler)
+	r.POST("/batches/:id/cancel", h.CancelBatchHandler)
+
+	do := func(method, path string) (int, BatchStatus) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var status BatchStatus
+		_ = json.Unmarshal(w.Body.Bytes(), &status)
+		return w.Code, status
+	}
+
+	code, status := do(http.MethodGet, "/batches/b1")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	// t4 is not part of the batch, the fourth message was not consumed yet and
+	// the failed one may still be retried
+	if status.Counts["SENT"] != 1 || status.Counts["FAILED"] != 1 || status.Counts["QUEUED"] != 1 || status.Pending != 3 || status.Progress != 0.25 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	code, status = do(http.MethodPost, "/batches/b1/cancel")
+	if code != http.StatusOK || status.Status != repository.BatchCancelled {
+		t.Fatalf("unexpected cancel response: %d %+v", code, status)
+	}
+	if status.Counts["CANCELLED"] != 1 || status.Counts["QUEUED"] != 0 {
+		t.Fatalf("queued message not cancelled: %+v", status.Counts)
+	}
+	var other models.Message
+	db.Where("tracking_id = ?", "t4").First(&other)
+	if other.Status != "QUEUED" {
+		t.Fatalf("message outside the batch changed: %s", other.Status)
+	}
+
+	if code, _ := do(http.MethodGet, "/batches/missing"); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/batches/missing/cancel"); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+}
+
+func TestCancelBatchCancelsScheduledMessages(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Batch{}, &models.Message{}, &models.MessageEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := repository.NewBatchRepository(db)
+	if err := repo.EnsureBatch("b1", 2); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	sendAt := time.Now().Add(time.Hour)
+	msgs := []models.Message{
+		{TrackingID: "t1", BatchID: "b1", Status: "SCHEDULED", SendAt: &sendAt},
+		{TrackingID: "t2", BatchID: "b1", Status: "SCHEDULED", SendAt: &sendAt},
+	}
+	if err := db.Create(&msgs).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+
+	n, err := repo.CancelBatch("b1")
+	if err != nil || n != 2 {
+		t.Fatalf("expected two cancelled messages, got %d %v", n, err)
+	}
+	scheduled, total, err := repository.NewMessageRepository(db).GetScheduledMessages(10, 0)
+	if err != nil || total != 0 || len(scheduled) != 0 {
+		t.Fatalf("cancelled messages must not stay scheduled: %d %v", total, err)
+	}
+	counts, _ := repo.CountByStatus("b1")
+	if counts["CANCELLED"] != 2 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/shared/phone"
+)
+
+// maxBlocklistImportBytes bounds the size of an uploaded blocklist CSV.
+const maxBlocklistImportBytes = 10 << 20
+
+// BlocklistHandlers serves the blocklist API. Phone numbers are normalized
+// for Country before they are stored.
+type BlocklistHandlers struct {
+	Repo    *repository.BlocklistRepository
+	Country string
+}
+
+// NewBlocklistHandlers creates a new BlocklistHandlers instance.
+func NewBlocklistHandlers(repo *repository.BlocklistRepository, country string) *BlocklistHandlers {
+	return &BlocklistHandlers{Repo: repo, Country: country}
+}
+
+// BlocklistRequest is the payload for blocking a number. An empty Client
+// blocks the number for every client.
+type BlocklistRequest struct {
+	Phone  string `json:"phone" binding:"required"`
+	Client string `json:"client"`
+	Reason string `json:"reason"`
+}
+
+// ImportError describes a CSV line that could not be imported.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ListBlocklistHandler returns a page of blocklist entries, optionally only
+// those of one client.
+func (h *BlocklistHandlers) ListBlocklistHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	items, total, err := h.Repo.GetEntries(c.Query("client"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list blocklist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// CreateBlocklistHandler blocks a number globally or for one client.
+func (h *BlocklistHandlers) CreateBlocklistHandler(c *gin.Context) {
+	var req BlocklistRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	number, err := phone.Normalize(req.Phone, h.Country)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry := models.BlocklistEntry{Phone: number, Client: strings.TrimSpace(req.Client), Reason: req.Reason}
+	added, err := h.Repo.AddEntries([]models.BlocklistEntry{entry})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not add entry"})
+		return
+	}
+	if added == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "number is already blocked"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"phone": entry.Phone, "client": entry.Client})
+}
+
+// DeleteBlocklistHandler unblocks an entry by ID.
+func (h *BlocklistHandlers) DeleteBlocklistHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deleted, err := h.Repo.DeleteEntry(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete entry"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ImportBlocklistHandler adds the numbers of a CSV file with the columns
+// phone, client and reason; only phone is required and a header row is
+// skipped. The file is sent as the "file" field of a multipart form or as
+// the request body. The client query parameter applies to rows without a
+// client. Valid rows are imported even when others are rejected.
+func (h *BlocklistHandlers) ImportBlocklistHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBlocklistImportBytes)
+	var src io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+			return
+		}
+		defer f.Close()
+		src = f
+	}
+
+	entries, errs, err := parseBlocklistCSV(src, c.Query("client"), h.Country)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	added, err := h.Repo.AddEntries(entries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not import blocklist"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"imported": added,
+		"skipped":  int64(len(entries)) - added,
+		"errors":   errs,
+	})
+}
+
+// parseBlocklistCSV reads blocklist entries from CSV, normalizing phone
+// numbers for country. Lines with invalid numbers are reported instead of
+// returned; a malformed file fails as a whole.
+func parseBlocklistCSV(r io.Reader, client, country string) ([]models.BlocklistEntry, []ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	entries := []models.BlocklistEntry{}
+	errs := []ImportError{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "phone") {
+			continue
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		number, err := phone.Normalize(record[0], country)
+		if err != nil {
+			errs = append(errs, ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+		entry := models.BlocklistEntry{Phone: number, Client: client}
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			entry.Client = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			entry.Reason = strings.TrimSpace(record[2])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, errs, nil
+}
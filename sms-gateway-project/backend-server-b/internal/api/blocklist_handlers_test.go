@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+func newBlocklistTestRouter(t *testing.T) (*gin.Engine, *repository.BlocklistRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.BlocklistEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := repository.NewBlocklistRepository(db)
+	h := NewBlocklistHandlers(repo, "IR")
+	r := gin.New()
+	r.GET("/blocklist", h.ListBlocklistHandler)
+	r.POST("/blocklist", h.CreateBlocklistHandler)
+	r.DELETE("/blocklist/:id", h.DeleteBlocklistHandler)
+	r.POST("/blocklist/import", h.ImportBlocklistHandler)
+	return r, repo
+}
+
+func TestBlocklistHandlers(t *testing.T) {
+	r, repo := newBlocklistTestRouter(t)
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/blocklist", bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	if code := post(`{"phone":"09121234567","reason":"asked to stop"}`); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if code := post(`{"phone":"+989121234567"}`); code != http.StatusConflict {
+		t.Fatalf("expected 409 for the same number, got %d", code)
+	}
+	if code := post(`{"phone":"09121234567","client":"shop"}`); code != http.StatusCreated {
+		t.Fatalf("expected 201 for a client entry, got %d", code)
+	}
+	if code := post(`{"phone":"12"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid number, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocklist?client=shop", nil))
+	var list struct {
+		Items []models.BlocklistEntry `json:"items"`
+		Total int64                   `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Total != 1 || list.Items[0].Client != "shop" || list.Items[0].Phone != "+989121234567" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	entry, found, err := repo.FindBlock("+989121234567", "shop")
+	if err != nil || !found || entry.Client != "" {
+		t.Fatalf("expected the global entry to win: %+v %v %v", entry, found, err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/blocklist/"+strconv.Itoa(int(list.Items[0].ID)), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/blocklist/"+strconv.Itoa(int(list.Items[0].ID)), nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestImportBlocklistHandler(t *testing.T) {
+	r, repo := newBlocklistTestRouter(t)
+	csvData := "phone,client,reason\n09121234567\n+989130000000,shop,complaint\n\nnot-a-number\n09121234567\n"
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "blocklist.csv")
+	part.Write([]byte(csvData))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/blocklist/import?client=default", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp struct {
+		Imported int64         `json:"imported"`
+		Skipped  int64         `json:"skipped"`
+		Errors   []ImportError `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Imported != 2 || resp.Skipped != 1 {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Line != 5 {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+	if _, found, _ := repo.FindBlock("+989121234567", "default"); !found {
+		t.Fatal("row without client not imported for the default client")
+	}
+	if _, found, _ := repo.FindBlock("+989130000000", "other"); found {
+		t.Fatal("client entry applied to another client")
+	}
+
+	// the raw request body works as well
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/blocklist/import", bytes.NewBufferString("09140000000\n")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if _, found, _ := repo.FindBlock("+989140000000", "any"); !found {
+		t.Fatal("number from the request body not imported globally")
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+func TestGetDashboardStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Message{}, &models.MessageEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	msgs := []models.Message{
+		{TrackingID: "t1", Status: "SENT"},
+		{TrackingID: "t2", Status: "DELIVERED"},
+		{TrackingID: "t3", Status: "FAILED"},
+		{TrackingID: "t4", Status: "UNDELIVERED"},
+		{TrackingID: "t5", Status: "REJECTED"},
+		{TrackingID: "t6", Status: "EXPIRED"},
+		{TrackingID: "t7", Status: "QUEUED"},
+	}
+	if err := db.Create(&msgs).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+
+	h := NewHandlers(repository.NewMessageRepository(db), nil, nil)
+	r := gin.New()
+	r.GET("/dashboard", h.GetDashboardStatsHandler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var stats repository.DashboardStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := repository.DashboardStats{Total: 7, Sent: 1, Delivered: 1, Failed: 4}
+	if stats != want {
+		t.Fatalf("expected %+v, got %+v", want, stats)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// Replayer publishes a message body back onto the main queue.
+type Replayer interface {
+	Replay(ctx context.Context, body []byte) error
+}
+
+// DeadLetterHandlers serves the dead-letter queue admin API.
+type DeadLetterHandlers struct {
+	Repo     *repository.DeadLetterRepository
+	Replayer Replayer
+}
+
+// NewDeadLetterHandlers creates a new DeadLetterHandlers instance.
+func NewDeadLetterHandlers(repo *repository.DeadLetterRepository, replayer Replayer) *DeadLetterHandlers {
+	return &DeadLetterHandlers{Repo: repo, Replayer: replayer}
+}
+
+// ListDeadLettersHandler returns a page of dead-lettered messages.
+func (h *DeadLetterHandlers) ListDeadLettersHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	items, total, err := h.Repo.GetDeadLetters(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// GetDeadLetterHandler returns a single dead-lettered message.
+func (h *DeadLetterHandlers) GetDeadLetterHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	dl, err := h.Repo.GetDeadLetterByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
+
+// ReplayDeadLetterHandler puts a dead-lettered message back on the main queue.
+func (h *DeadLetterHandlers) ReplayDeadLetterHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	dl, err := h.Repo.GetDeadLetterByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := h.Replayer.Replay(c, []byte(dl.Body)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not replay message"})
+		return
+	}
+	if err := h.Repo.DeleteDeadLetter(dl.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete dead letter"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "replayed"})
+}
+
+// DeleteDeadLetterHandler discards a single dead-lettered message.
+func (h *DeadLetterHandlers) DeleteDeadLetterHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.Repo.DeleteDeadLetter(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete dead letter"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// PurgeDeadLettersHandler discards every dead-lettered message.
+func (h *DeadLetterHandlers) PurgeDeadLettersHandler(c *gin.Context) {
+	n, err := h.Repo.PurgeDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not purge dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "purged", "deleted": n})
+}
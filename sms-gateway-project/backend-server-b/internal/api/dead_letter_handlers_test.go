@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+type fakeReplayer struct {
+	bodies []string
+}
+
+func (f *fakeReplayer) Replay(ctx context.Context, body []byte) error {
+	f.bodies = append(f.bodies, string(body))
+	return nil
+}
+
+func TestDeadLetterHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DeadLetter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := repository.NewDeadLetterRepository(db)
+	first := models.DeadLetter{TrackingID: "t1", Body: `{"tracking_id":"t1"}`, Reason: "rejected", Attempts: 5}
+	second := models.DeadLetter{Body: `not json`, Reason: "malformed payload", Attempts: 1}
+	for _, dl := range []*models.DeadLetter{&first, &second} {
+		if err := repo.CreateDeadLetter(dl); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	replayer := &fakeReplayer{}
+	h := NewDeadLetterHandlers(repo, replayer)
+	r := gin.Default()
+	r.GET("/dlq", h.ListDeadLettersHandler)
+	r.GET("/dlq/:id", h.GetDeadLetterHandler)
+	r.POST("/dlq/:id/replay", h.ReplayDeadLetterHandler)
+	r.DELETE("/dlq", h.PurgeDeadLettersHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dlq", nil))
+	var list struct {
+		Items []models.DeadLetter `json:"items"`
+		Total int64               `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Total != 2 {
+		t.Fatalf("unexpected list response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/dlq/%d", first.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/dlq/%d/replay", first.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if len(replayer.bodies) != 1 || replayer.bodies[0] != first.Body {
+		t.Fatalf("expected body to be replayed, got %v", replayer.bodies)
+	}
+	if _, err := repo.GetDeadLetterByID(first.ID); err == nil {
+		t.Fatal("expected replayed dead letter to be removed")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/dlq", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if _, total, _ := repo.GetDeadLetters(10, 0); total != 0 {
+		t.Fatalf("expected no dead letters after purge, got %d", total)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"sms-gateway/backend-server-b/internal/dlr"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// ProviderTypes resolves a provider name to its configured type.
+type ProviderTypes interface {
+	ProviderType(name string) string
+}
+
+// DeliveryHandlers applies provider delivery reports to messages.
+type DeliveryHandlers struct {
+	Repo      *repository.MessageRepository
+	Providers ProviderTypes
+}
+
+// NewDeliveryHandlers creates a new DeliveryHandlers instance.
+func NewDeliveryHandlers(repo *repository.MessageRepository, providers ProviderTypes) *DeliveryHandlers {
+	return &DeliveryHandlers{Repo: repo, Providers: providers}
+}
+
+// DeliveryWebhookHandler parses a delivery report callback with the parser of
+// the provider's type and records every report on its message. Final statuses
+// replace the message status; UNKNOWN ones are only added to its history.
+// It responds with 404 when none of the reported messages exist.
+func (h *DeliveryHandlers) DeliveryWebhookHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	reports, err := dlr.ForProvider(h.Providers.ProviderType(provider)).Parse(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	processed := 0
+	for _, report := range reports {
+		msg, err := h.Repo.FindMessageByProviderRef(report.ProviderRef)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record report"})
+			return
+		}
+		if report.Final() {
+			if err := h.Repo.UpdateMessageStatus(msg.TrackingID, report.Status, msg.ProviderRef); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record report"})
+				return
+			}
+		}
+		description := fmt.Sprintf("delivery report from %s: %s", provider, report.Status)
+		if err := h.Repo.CreateDeliveryEvent(msg.ID, description, report.Code, report.Timestamp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record report"})
+			return
+		}
+		processed++
+	}
+	if processed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "processed": processed})
+}
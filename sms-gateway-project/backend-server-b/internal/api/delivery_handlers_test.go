@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// providerTypes is a fixed ProviderTypes for tests.
+type providerTypes map[string]string
+
+func (p providerTypes) ProviderType(name string) string {
+	if kind, ok := p[name]; ok {
+		return kind
+	}
+	return name
+}
+
+func TestDeliveryWebhookHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Message{}, &models.MessageEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	msgs := []models.Message{
+		{TrackingID: "t1", Status: "SENT", ProviderRef: "101"},
+		{TrackingID: "t2", Status: "SENT", ProviderRef: "102"},
+		{TrackingID: "t3", Status: "SENT", ProviderRef: "r3"},
+	}
+	if err := db.Create(&msgs).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+
+	repo := repository.NewMessageRepository(db)
+	h := NewDeliveryHandlers(repo, providerTypes{"magfa-prod": "magfa"})
+	r := gin.New()
+	r.POST("/webhooks/delivery-report/:provider", WebhookAuthMiddleware("s3cret"), h.DeliveryWebhookHandler)
+
+	post := func(provider, contentType, body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/delivery-report/"+provider, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Webhook-Secret", "s3cret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	code, out := post("magfa-prod", "application/json", `{"dlrs":[{"mid":101,"status":1,"date":"2024-01-01 10:00:00"},{"mid":102,"status":8},{"mid":999,"status":1}]}`)
+	if code != http.StatusOK || out["processed"] != float64(2) {
+		t.Fatalf("unexpected response: %d %v", code, out)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "DELIVERED" || len(msg.Events) != 1 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if ev := msg.Events[0]; ev.ProviderCode != "1" || ev.OccurredAt == nil || ev.Event != "delivery report from magfa-prod: DELIVERED" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	// a report that is not final is recorded without touching the status
+	msg, _ = repo.GetMessageByTrackingID("t2")
+	if msg.Status != "SENT" || len(msg.Events) != 1 || msg.Events[0].ProviderCode != "8" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	if code, _ := post("provider-a", "application/json", `{"provider_ref":"r3","status":"expired"}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if msg, _ := repo.GetMessageByTrackingID("t3"); msg.Status != "EXPIRED" || msg.Events[0].ProviderCode != "expired" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	if code, _ := post("provider-a", "application/json", `{"provider_ref":"missing","status":"delivered"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown ref, got %d", code)
+	}
+	if code, _ := post("magfa-prod", "application/x-www-form-urlencoded", "status=1"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a report without mid, got %d", code)
+	}
+}
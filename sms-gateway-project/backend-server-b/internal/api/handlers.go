@@ -36,31 +36,6 @@ func (h *Handlers) GetStatusHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, msg)
 }
 
-// DeliveryWebhookHandler handles delivery status callbacks from providers.
-func (h *Handlers) DeliveryWebhookHandler(c *gin.Context) {
-	provider := c.Param("provider")
-	var payload struct {
-		ProviderRef string `json:"provider_ref"`
-		Status      string `json:"status"`
-	}
-	if err := c.BindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-		return
-	}
-	msg, err := h.MessageRepo.FindMessageByProviderRef(payload.ProviderRef)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
-	status := "DELIVERED"
-	if payload.Status != "delivered" {
-		status = "FAILED_DELIVERY"
-	}
-	_ = h.MessageRepo.UpdateMessageStatus(msg.TrackingID, status, payload.ProviderRef)
-	_ = h.MessageRepo.CreateMessageEvent(msg.TrackingID, "webhook from "+provider)
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-}
-
 // LoginRequest represents credentials for login.
 type LoginRequest struct {
 	Username string `json:"username"`
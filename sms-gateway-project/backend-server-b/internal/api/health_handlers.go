@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/worker"
+)
+
+// ConsumerMonitor reports the state of the queue consumer.
+type ConsumerMonitor interface {
+	Status() worker.ConsumerStatus
+}
+
+// HealthHandler reports whether the worker is consuming messages. It responds
+// with 503 while the consumer is reconnecting or stopped so orchestrators can
+// restart or route around the instance.
+func HealthHandler(consumer ConsumerMonitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := consumer.Status()
+		if !status.Healthy() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "consumer": status})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "consumer": status})
+	}
+}
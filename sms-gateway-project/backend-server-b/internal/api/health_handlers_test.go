@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/worker"
+)
+
+type fakeMonitor struct {
+	status worker.ConsumerStatus
+}
+
+func (f fakeMonitor) Status() worker.ConsumerStatus { return f.status }
+
+func TestHealthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		state worker.ConsumerState
+		code  int
+		want  string
+	}{
+		{worker.StateConsuming, http.StatusOK, "ok"},
+		{worker.StateReconnecting, http.StatusServiceUnavailable, "degraded"},
+		{worker.StateStopped, http.StatusServiceUnavailable, "degraded"},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.GET("/health", HealthHandler(fakeMonitor{worker.ConsumerStatus{State: tc.state, LastError: "boom"}}))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d", tc.state, tc.code, w.Code)
+		}
+		var body struct {
+			Status   string                `json:"status"`
+			Consumer worker.ConsumerStatus `json:"consumer"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if body.Status != tc.want || body.Consumer.State != tc.state {
+			t.Fatalf("%s: unexpected body %s", tc.state, w.Body.String())
+		}
+	}
+}
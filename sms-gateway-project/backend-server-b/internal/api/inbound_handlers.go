@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/shared/phone"
+)
+
+// InboundHandlers receives mobile-originated messages from providers and
+// serves them to the panel. Senders whose message starts with one of
+// Keywords are added to the global blocklist.
+type InboundHandlers struct {
+	Repo      *repository.InboundRepository
+	Blocklist *repository.BlocklistRepository
+	Keywords  []string
+	Country   string
+}
+
+// NewInboundHandlers creates a new InboundHandlers instance.
+func NewInboundHandlers(repo *repository.InboundRepository, blocklist *repository.BlocklistRepository, keywords []string, country string) *InboundHandlers {
+	return &InboundHandlers{Repo: repo, Blocklist: blocklist, Keywords: keywords, Country: country}
+}
+
+// InboundRequest is an inbound message callback, sent as JSON or as a form.
+// ID is the provider's reference and makes retried callbacks idempotent.
+type InboundRequest struct {
+	ID         string     `json:"id" form:"id"`
+	From       string     `json:"from" form:"from" binding:"required"`
+	To         string     `json:"to" form:"to"`
+	Text       string     `json:"text" form:"text"`
+	ReceivedAt *time.Time `json:"received_at" form:"received_at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// InboundWebhookHandler stores an inbound message and processes opt-outs.
+func (h *InboundHandlers) InboundWebhookHandler(c *gin.Context) {
+	var req InboundRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	provider := c.Param("provider")
+	if req.ID != "" {
+		seen, err := h.Repo.Exists(provider, req.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store message"})
+			return
+		}
+		if seen {
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+			return
+		}
+	}
+
+	msg := models.InboundMessage{
+		Provider:    provider,
+		ProviderRef: req.ID,
+		Sender:      req.From,
+		Receiver:    req.To,
+		Text:        req.Text,
+		ReceivedAt:  time.Now().UTC(),
+	}
+	if req.ReceivedAt != nil {
+		msg.ReceivedAt = req.ReceivedAt.UTC()
+	}
+	sender, err := phone.Normalize(req.From, h.Country)
+	if err == nil {
+		msg.Sender = sender
+	}
+	// the opt-out is recorded before the message so a failure here is
+	// retried by the provider instead of being hidden by the duplicate check
+	if err == nil && isOptOut(req.Text, h.Keywords) {
+		entry := models.BlocklistEntry{Phone: sender, Reason: fmt.Sprintf("opt-out via %s: %s", provider, strings.TrimSpace(req.Text))}
+		if _, err := h.Blocklist.AddEntries([]models.BlocklistEntry{entry}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record opt-out"})
+			return
+		}
+		msg.OptOut = true
+	}
+	if err := h.Repo.CreateInboundMessage(&msg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store message"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "received", "opt_out": msg.OptOut})
+}
+
+// ListInboundHandler returns a page of inbound messages, optionally only
+// those of one sender.
+func (h *InboundHandlers) ListInboundHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	sender := c.Query("sender")
+	if n, err := phone.Normalize(sender, h.Country); err == nil {
+		sender = n
+	}
+	items, total, err := h.Repo.GetInboundMessages(sender, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list inbound messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// isOptOut reports whether the first word of text is one of keywords,
+// ignoring case, surrounding punctuation and Arabic forms of Persian letters.
+func isOptOut(text string, keywords []string) bool {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return false
+	}
+	first := keywordForm(words[0])
+	if first == "" {
+		return false
+	}
+	for _, k := range keywords {
+		if first == keywordForm(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// arabicLetters maps Arabic code points to the Persian letters phones
+// commonly substitute them for.
+var arabicLetters = strings.NewReplacer("ي", "ی", "ك", "ک")
+
+// keywordForm returns word in the form keywords are compared in.
+func keywordForm(word string) string {
+	word = strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.ToLower(arabicLetters.Replace(word))
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+func TestInboundHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.InboundMessage{}, &models.BlocklistEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	blocklist := repository.NewBlocklistRepository(db)
+	h := NewInboundHandlers(repository.NewInboundRepository(db), blocklist, []string{"STOP", "لغو"}, "IR")
+	r := gin.New()
+	r.POST("/webhooks/inbound/:provider", WebhookAuthMiddleware("s3cret"), h.InboundWebhookHandler)
+	r.GET("/inbound", h.ListInboundHandler)
+
+	postJSON := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/inbound/magfa", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Webhook-Secret", "s3cret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	code, out := postJSON(`{"id":"m1","from":"09121234567","to":"3000","text":"Hello there"}`)
+	if code != http.StatusOK || out["opt_out"] != false {
+		t.Fatalf("unexpected response: %d %v", code, out)
+	}
+	code, out = postJSON(`{"id":"m2","from":"09121234567","to":"3000","text":"  لغو  ","received_at":"2024-01-01T10:00:00Z"}`)
+	if code != http.StatusOK || out["opt_out"] != true {
+		t.Fatalf("unexpected response: %d %v", code, out)
+	}
+	if code, out := postJSON(`{"id":"m2","from":"09121234567","text":"لغو"}`); code != http.StatusOK || out["status"] != "duplicate" {
+		t.Fatalf("expected a duplicate, got %d %v", code, out)
+	}
+	if code, _ := postJSON(`{"text":"STOP"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a sender, got %d", code)
+	}
+	if _, found, _ := blocklist.FindBlock("+989121234567", "any"); !found {
+		t.Fatal("sender not added to the blocklist")
+	}
+
+	// form-encoded callbacks with the secret in the query string
+	form := url.Values{"id": {"m3"}, "from": {"+989130000000"}, "text": {"stop!"}}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/inbound/magfa?secret=s3cret", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, found, _ := blocklist.FindBlock("+989130000000", "any"); !found {
+		t.Fatal("form sender not added to the blocklist")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks/inbound/magfa", bytes.NewBufferString(`{"from":"09121234567","text":"STOP"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the secret, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/inbound?sender=09121234567", nil))
+	var list struct {
+		Items []models.InboundMessage `json:"items"`
+		Total int64                   `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Total != 2 || list.Items[0].ProviderRef != "m1" || !list.Items[1].OptOut {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+}
+
+func TestIsOptOut(t *testing.T) {
+	keywords := []string{"STOP", "لغو"}
+	cases := map[string]bool{
+		"STOP":           true,
+		"stop please":    true,
+		"Stop.":          true,
+		"لغو":            true,
+		"لغو ۱۲":         true,
+		"nonstop":        false,
+		"please stop":    false,
+		"":               false,
+		"!!!":            false,
+		"unsubscribe me": false,
+	}
+	for text, want := range cases {
+		if got := isOptOut(text, keywords); got != want {
+			t.Errorf("%q: expected %v", text, want)
+		}
+	}
+	if !isOptOut("كنسل", []string{"کنسل"}) {
+		t.Error("Arabic kaf not matched to the Persian keyword")
+	}
+}
+
+func TestWebhookAuthMiddlewareWithoutSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/hook", WebhookAuthMiddleware(""), func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hook?secret=", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 
@@ -40,3 +41,35 @@ func AdminOnlyMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// InternalAuthMiddleware guards endpoints meant for server A with a shared key
+// sent in the X-Internal-API-Key header. Without a configured key every
+// request is refused.
+func InternalAuthMiddleware(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := c.GetHeader("X-Internal-API-Key")
+		if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid internal api key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// WebhookAuthMiddleware guards provider callbacks with a shared secret, sent
+// in the X-Webhook-Secret header or, for providers that cannot set headers,
+// the secret query parameter. Without a configured secret every request is
+// refused.
+func WebhookAuthMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := c.GetHeader("X-Webhook-Secret")
+		if given == "" {
+			given = c.Query("secret")
+		}
+		if secret == "" || subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook secret"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// ScheduledHandlers serves the scheduled message API.
+type ScheduledHandlers struct {
+	Repo *repository.MessageRepository
+}
+
+// NewScheduledHandlers creates a new ScheduledHandlers instance.
+func NewScheduledHandlers(repo *repository.MessageRepository) *ScheduledHandlers {
+	return &ScheduledHandlers{Repo: repo}
+}
+
+// ListScheduledHandler returns a page of messages waiting for their send time.
+func (h *ScheduledHandlers) ListScheduledHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	items, total, err := h.Repo.GetScheduledMessages(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list scheduled messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// CancelScheduledHandler cancels a scheduled message before it is released.
+func (h *ScheduledHandlers) CancelScheduledHandler(c *gin.Context) {
+	trackingID := c.Param("tracking_id")
+	if _, err := h.Repo.GetMessageByTrackingID(trackingID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	cancelled, err := h.Repo.CancelScheduledMessage(trackingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not cancel message"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "message is not scheduled"})
+		return
+	}
+	_ = h.Repo.CreateMessageEvent(trackingID, "scheduled send cancelled")
+	c.JSON(http.StatusOK, gin.H{"status": "cancelled"})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+func TestScheduledHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Message{}, &models.MessageEvent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	sendAt := time.Now().Add(time.Hour)
+	msgs := []models.Message{
+		{TrackingID: "t1", Status: "SCHEDULED", SendAt: &sendAt},
+		{TrackingID: "t2", Status: "SENT"},
+	}
+	if err := db.Create(&msgs).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+
+	h := NewScheduledHandlers(repository.NewMessageRepository(db))
+	r := gin.New()
+	r.GET("/scheduled", h.ListScheduledHandler)
+	r.DELETE("/scheduled/:tracking_id", h.CancelScheduledHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scheduled", nil))
+	var list struct {
+		Items []models.Message `json:"items"`
+		Total int64            `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected list response: %d %s", w.Code, w.Body.String())
+	}
+	if list.Total != 1 || len(list.Items) != 1 || list.Items[0].TrackingID != "t1" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	cancel := func(id string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/scheduled/"+id, nil))
+		return w.Code
+	}
+	if code := cancel("t1"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	var msg models.Message
+	db.Where("tracking_id = ?", "t1").First(&msg)
+	if msg.Status != "CANCELLED" {
+		t.Fatalf("expected CANCELLED, got %s", msg.Status)
+	}
+	if code := cancel("t1"); code != http.StatusConflict {
+		t.Fatalf("expected 409 for an already cancelled message, got %d", code)
+	}
+	if code := cancel("t2"); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a sent message, got %d", code)
+	}
+	if code := cancel("missing"); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+}
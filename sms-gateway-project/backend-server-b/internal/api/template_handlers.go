@@ -0,0 +1,218 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/shared/template"
+)
+
+// templateIDPattern restricts template IDs to URL-safe names such as "otp-login".
+var templateIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// TemplateHandlers serves the template API.
+type TemplateHandlers struct {
+	Repo *repository.TemplateRepository
+}
+
+// NewTemplateHandlers creates a new TemplateHandlers instance.
+func NewTemplateHandlers(repo *repository.TemplateRepository) *TemplateHandlers {
+	return &TemplateHandlers{Repo: repo}
+}
+
+// TemplateRequest is the payload for creating or replacing a template. The
+// default language may be omitted when there is a single variant.
+type TemplateRequest struct {
+	ID              string             `json:"id"`
+	Description     string             `json:"description"`
+	DefaultLanguage string             `json:"default_language"`
+	Variants        []template.Variant `json:"variants"`
+}
+
+// TemplateVariantResponse is one language of a template with the variables
+// its body uses.
+type TemplateVariantResponse struct {
+	Language  string   `json:"language"`
+	Body      string   `json:"body"`
+	Variables []string `json:"variables"`
+}
+
+// TemplateResponse is a template as returned by the API.
+type TemplateResponse struct {
+	ID              string                    `json:"id"`
+	Description     string                    `json:"description"`
+	DefaultLanguage string                    `json:"default_language"`
+	Variants        []TemplateVariantResponse `json:"variants"`
+	CreatedAt       time.Time                 `json:"created_at"`
+	UpdatedAt       time.Time                 `json:"updated_at"`
+}
+
+// ListTemplatesHandler returns all templates.
+func (h *TemplateHandlers) ListTemplatesHandler(c *gin.Context) {
+	tpls, err := h.Repo.ListTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list templates"})
+		return
+	}
+	items := make([]TemplateResponse, 0, len(tpls))
+	for _, tpl := range tpls {
+		items = append(items, templateResponse(tpl))
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetTemplateHandler returns a template by ID.
+func (h *TemplateHandlers) GetTemplateHandler(c *gin.Context) {
+	tpl, err := h.Repo.GetTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, templateResponse(tpl))
+}
+
+// CreateTemplateHandler adds a new template.
+func (h *TemplateHandlers) CreateTemplateHandler(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	tpl, err := templateFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.Repo.GetTemplate(tpl.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "template already exists"})
+		return
+	}
+	if err := h.Repo.CreateTemplate(&tpl); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create template"})
+		return
+	}
+	c.JSON(http.StatusCreated, templateResponse(tpl))
+}
+
+// UpdateTemplateHandler replaces a template's description and variants.
+func (h *TemplateHandlers) UpdateTemplateHandler(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.ID = c.Param("id")
+	tpl, err := templateFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Repo.UpdateTemplate(&tpl); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update template"})
+		return
+	}
+	updated, err := h.Repo.GetTemplate(tpl.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load template"})
+		return
+	}
+	c.JSON(http.StatusOK, templateResponse(updated))
+}
+
+// DeleteTemplateHandler removes a template by ID.
+func (h *TemplateHandlers) DeleteTemplateHandler(c *gin.Context) {
+	deleted, err := h.Repo.DeleteTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete template"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// InternalTemplateHandler serves a template to server A in the shared
+// template format.
+func (h *TemplateHandlers) InternalTemplateHandler(c *gin.Context) {
+	tpl, err := h.Repo.GetTemplate(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load template"})
+		return
+	}
+	out := template.Template{ID: tpl.ID, DefaultLanguage: tpl.DefaultLanguage}
+	for _, v := range tpl.Variants {
+		out.Variants = append(out.Variants, template.Variant{Language: v.Language, Body: v.Body})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// templateFromRequest validates a template request and converts it to a model.
+// Languages are stored in lower case.
+func templateFromRequest(req TemplateRequest) (models.Template, error) {
+	if !templateIDPattern.MatchString(req.ID) {
+		return models.Template{}, errors.New("id must be 1-64 letters, digits, '-' or '_'")
+	}
+	if len(req.Variants) == 0 {
+		return models.Template{}, errors.New("at least one variant is required")
+	}
+	tpl := models.Template{
+		ID:              req.ID,
+		Description:     req.Description,
+		DefaultLanguage: strings.ToLower(strings.TrimSpace(req.DefaultLanguage)),
+	}
+	seen := map[string]bool{}
+	for _, v := range req.Variants {
+		lang := strings.ToLower(strings.TrimSpace(v.Language))
+		switch {
+		case lang == "":
+			return models.Template{}, errors.New("every variant needs a language")
+		case seen[lang]:
+			return models.Template{}, errors.New("duplicate variant language " + lang)
+		case strings.TrimSpace(v.Body) == "":
+			return models.Template{}, errors.New("variant " + lang + " has an empty body")
+		}
+		seen[lang] = true
+		tpl.Variants = append(tpl.Variants, models.TemplateVariant{TemplateID: req.ID, Language: lang, Body: v.Body})
+	}
+	if tpl.DefaultLanguage == "" && len(tpl.Variants) == 1 {
+		tpl.DefaultLanguage = tpl.Variants[0].Language
+	}
+	if !seen[tpl.DefaultLanguage] {
+		return models.Template{}, errors.New("default_language must match one of the variants")
+	}
+	return tpl, nil
+}
+
+// templateResponse converts a template model for the API.
+func templateResponse(tpl models.Template) TemplateResponse {
+	resp := TemplateResponse{
+		ID:              tpl.ID,
+		Description:     tpl.Description,
+		DefaultLanguage: tpl.DefaultLanguage,
+		Variants:        []TemplateVariantResponse{},
+		CreatedAt:       tpl.CreatedAt,
+		UpdatedAt:       tpl.UpdatedAt,
+	}
+	for _, v := range tpl.Variants {
+		resp.Variants = append(resp.Variants, TemplateVariantResponse{Language: v.Language, Body: v.Body, Variables: template.Variables(v.Body)})
+	}
+	return resp
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/shared/template"
+)
+
+func TestTemplateHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Template{}, &models.TemplateVariant{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	h := NewTemplateHandlers(repository.NewTemplateRepository(db))
+	r := gin.New()
+	r.GET("/templates", h.ListTemplatesHandler)
+	r.GET("/templates/:id", h.GetTemplateHandler)
+	r.POST("/templates", h.CreateTemplateHandler)
+	r.PUT("/templates/:id", h.UpdateTemplateHandler)
+	r.DELETE("/templates/:id", h.DeleteTemplateHandler)
+	r.GET("/internal/templates/:id", InternalAuthMiddleware("key"), h.InternalTemplateHandler)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Internal-API-Key", "key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/templates", `{"id":"otp","default_language":"fa","variants":[{"language":"FA","body":"کد شما {{code}}"},{"language":"en","body":"Hi {{name}}, your code is {{code}}"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created TemplateResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if len(created.Variants) != 2 || created.Variants[0].Language != "fa" || len(created.Variants[1].Variables) != 2 {
+		t.Fatalf("unexpected template: %+v", created)
+	}
+	if w := do(http.MethodPost, "/templates", `{"id":"otp","variants":[{"language":"en","body":"x"}]}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate id, got %d", w.Code)
+	}
+
+	invalid := []string{
+		`{"id":"bad id","variants":[{"language":"en","body":"x"}]}`,
+		`{"id":"t","variants":[]}`,
+		`{"id":"t","variants":[{"language":"en","body":" "}]}`,
+		`{"id":"t","variants":[{"language":"en","body":"x"},{"language":"EN","body":"y"}]}`,
+		`{"id":"t","variants":[{"language":"en","body":"x"},{"language":"fa","body":"y"}]}`,
+	}
+	for _, body := range invalid {
+		if w := do(http.MethodPost, "/templates", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	w = do(http.MethodPut, "/templates/otp", `{"description":"login code","variants":[{"language":"en","body":"Code {{code}}"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/templates/missing", `{"variants":[{"language":"en","body":"x"}]}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/internal/templates/otp", "")
+	var tpl template.Template
+	json.Unmarshal(w.Body.Bytes(), &tpl)
+	if w.Code != http.StatusOK || tpl.DefaultLanguage != "en" || len(tpl.Variants) != 1 || tpl.Variants[0].Body != "Code {{code}}" {
+		t.Fatalf("unexpected internal template: %d %+v", w.Code, tpl)
+	}
+	if w := do(http.MethodGet, "/internal/templates/missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/templates", "")
+	var list []TemplateResponse
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Description != "login code" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if w := do(http.MethodDelete, "/templates/otp", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/templates/otp", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", w.Code)
+	}
+	var variants int64
+	db.Model(&models.TemplateVariant{}).Count(&variants)
+	if variants != 0 {
+		t.Fatalf("variants left behind: %d", variants)
+	}
+}
+
+func TestInternalAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	cases := []struct {
+		key, header string
+		want        int
+	}{
+		{"secret", "secret", http.StatusOK},
+		{"secret", "wrong", http.StatusUnauthorized},
+		{"secret", "", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.GET("/internal", InternalAuthMiddleware(tc.key), ok)
+		req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+		if tc.header != "" {
+			req.Header.Set("X-Internal-API-Key", tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("key %q header %q: expected %d, got %d", tc.key, tc.header, tc.want, w.Code)
+		}
+	}
+}
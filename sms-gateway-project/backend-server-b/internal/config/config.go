@@ -2,16 +2,43 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strconv"
 	"strings" // Import the strings package
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"sms-gateway/shared/phone"
 )
 
 // ProviderConfig holds external SMS provider settings.
 type ProviderConfig struct {
-	APIURL string `json:"api_url"`
-	APIKey string `json:"api_key"`
+	Type         string            `json:"type"`
+	APIURL       string            `json:"api_url"`
+	APIKey       string            `json:"api_key"`
+	Username     string            `json:"username"`
+	Password     string            `json:"password"`
+	Sender       string            `json:"sender"`
+	ExtraHeaders map[string]string `json:"extra_headers"`
+	TimeoutMs    int               `json:"timeout_ms"`
+	// Retries is the number of extra attempts made on retryable errors.
+	Retries        int `json:"retries"`
+	RetryBackoffMs int `json:"retry_backoff_ms"`
+	// Priority orders providers for routing; lower values are tried first.
+	Priority int `json:"priority"`
+	// Weight splits traffic between providers of equal priority.
+	Weight int `json:"weight"`
+	// MaxConcurrency caps simultaneous sends through the provider; 0 means no limit.
+	MaxConcurrency int   `json:"max_concurrency"`
+	IsEnabled      *bool `json:"is_enabled"`
+}
+
+// Enabled reports whether the provider may be used; providers are enabled
+// unless explicitly switched off.
+func (p ProviderConfig) Enabled() bool {
+	return p.IsEnabled == nil || *p.IsEnabled
 }
 
 // Config holds application configuration loaded from environment variables.
@@ -25,6 +52,26 @@ type Config struct {
 	DefaultAdminPassword string
 	JWTSecretKey         string
 	AllowedOrigins       []string // Changed to slice of strings
+	// ProviderKMSKey is the hex AES key used to decrypt provider secrets stored in the database.
+	ProviderKMSKey string
+	// ProviderRefreshInterval controls how often providers are reloaded from the database.
+	ProviderRefreshInterval time.Duration
+	// MaxDeliveryAttempts is how often a message is delivered before it is dead-lettered.
+	MaxDeliveryAttempts int
+	// RetryDelays are the waits of the retry queue tiers, shortest first.
+	RetryDelays []time.Duration
+	// WorkerConcurrency is the number of messages processed in parallel.
+	WorkerConcurrency int
+	// SchedulerInterval controls how often due scheduled messages are released.
+	SchedulerInterval time.Duration
+	// InternalAPIKey authenticates server A on the /internal endpoints.
+	InternalAPIKey string
+	// DefaultCountry is the ISO code national recipient numbers belong to.
+	DefaultCountry string
+	// WebhookSecret authenticates provider callbacks on the /webhooks endpoints.
+	WebhookSecret string
+	// OptOutKeywords are the inbound message keywords that block the sender.
+	OptOutKeywords []string
 }
 
 // LoadConfig loads configuration from environment variables and .env files.
@@ -40,6 +87,18 @@ func LoadConfig() (*Config, error) {
 		DefaultAdminUsername: os.Getenv("DEFAULT_ADMIN_USERNAME"),
 		DefaultAdminPassword: os.Getenv("DEFAULT_ADMIN_PASSWORD"),
 		JWTSecretKey:         os.Getenv("JWT_SECRET_KEY"),
+		ProviderKMSKey:       os.Getenv("PROVIDER_KMS_KEY"),
+		InternalAPIKey:       os.Getenv("INTERNAL_API_KEY"),
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+	}
+
+	cfg.ProviderRefreshInterval = 30 * time.Second
+	if v := os.Getenv("PROVIDER_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ProviderRefreshInterval = d
 	}
 
 	// Load and split AllowedOrigins
@@ -47,6 +106,61 @@ func LoadConfig() (*Config, error) {
 		cfg.AllowedOrigins = strings.Split(origins, ",")
 	}
 
+	cfg.DefaultCountry = phone.DefaultCountry
+	if v := os.Getenv("DEFAULT_COUNTRY"); v != "" {
+		cfg.DefaultCountry = strings.ToUpper(v)
+	}
+	if _, ok := phone.Countries[cfg.DefaultCountry]; !ok {
+		return nil, fmt.Errorf("unsupported DEFAULT_COUNTRY %q", cfg.DefaultCountry)
+	}
+
+	cfg.OptOutKeywords = []string{"STOP", "لغو"}
+	if v := os.Getenv("OPT_OUT_KEYWORDS"); v != "" {
+		cfg.OptOutKeywords = nil
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				cfg.OptOutKeywords = append(cfg.OptOutKeywords, k)
+			}
+		}
+	}
+
+	cfg.SchedulerInterval = 5 * time.Second
+	if v := os.Getenv("SCHEDULER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SchedulerInterval = d
+	}
+
+	cfg.MaxDeliveryAttempts = 5
+	if v := os.Getenv("MAX_DELIVERY_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MaxDeliveryAttempts = n
+	}
+
+	cfg.WorkerConcurrency = 1
+	if v := os.Getenv("WORKER_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.WorkerConcurrency = n
+	}
+
+	if v := os.Getenv("RETRY_DELAYS"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			cfg.RetryDelays = append(cfg.RetryDelays, d)
+		}
+	}
+
 	if data := os.Getenv("PROVIDERS_CONFIG"); data != "" {
 		_ = json.Unmarshal([]byte(data), &cfg.Providers)
 	}
@@ -0,0 +1,58 @@
+// Package dlr turns provider delivery report callbacks into normalized
+// delivery statuses.
+package dlr
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Normalized delivery statuses. Every status except Unknown is final.
+const (
+	Delivered   = "DELIVERED"
+	Undelivered = "UNDELIVERED"
+	Expired     = "EXPIRED"
+	Rejected    = "REJECTED"
+	Unknown     = "UNKNOWN"
+)
+
+// ErrInvalidReport is returned when a callback cannot be parsed.
+var ErrInvalidReport = errors.New("invalid delivery report")
+
+// Report is a single delivery report. Code is the status exactly as the
+// provider sent it and Timestamp, when the provider sent one, is when the
+// status was reached.
+type Report struct {
+	ProviderRef string
+	Status      string
+	Code        string
+	Timestamp   *time.Time
+}
+
+// Final reports whether the report settles the message's status.
+func (r Report) Final() bool {
+	return r.Status != Unknown
+}
+
+// Parser reads the delivery reports of one provider's callback.
+type Parser interface {
+	Parse(r *http.Request) ([]Report, error)
+}
+
+// ForProvider returns the parser for a provider type, falling back to the
+// generic format for providers without their own.
+func ForProvider(kind string) Parser {
+	switch strings.ToLower(kind) {
+	case "magfa":
+		return MagfaParser{}
+	default:
+		return GenericParser{}
+	}
+}
+
+// isJSON reports whether the request carries a JSON body.
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "application/json")
+}
@@ -0,0 +1,100 @@
+package dlr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRequest(contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/delivery-report/p", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestForProvider(t *testing.T) {
+	if _, ok := ForProvider("Magfa").(MagfaParser); !ok {
+		t.Fatal("expected the Magfa parser")
+	}
+	if _, ok := ForProvider("provider-a").(GenericParser); !ok {
+		t.Fatal("expected the generic parser as fallback")
+	}
+}
+
+func TestGenericParser(t *testing.T) {
+	cases := map[string]string{
+		"delivered": Delivered,
+		"FAILED":    Undelivered,
+		"expired":   Expired,
+		"rejected":  Rejected,
+		"queued":    Unknown,
+	}
+	for status, want := range cases {
+		reports, err := GenericParser{}.Parse(newRequest("application/json", `{"provider_ref":"r1","status":"`+status+`"}`))
+		if err != nil || len(reports) != 1 {
+			t.Fatalf("%s: unexpected result %v %v", status, reports, err)
+		}
+		if reports[0].Status != want || reports[0].Code != status || reports[0].Timestamp != nil {
+			t.Errorf("%s: unexpected report %+v", status, reports[0])
+		}
+	}
+
+	reports, err := GenericParser{}.Parse(newRequest("application/x-www-form-urlencoded", "provider_ref=r2&status=delivered&timestamp=2024-01-01T10:00:00%2B03:30"))
+	if err != nil {
+		t.Fatalf("parse form: %v", err)
+	}
+	want := time.Date(2024, 1, 1, 6, 30, 0, 0, time.UTC)
+	if reports[0].ProviderRef != "r2" || reports[0].Timestamp == nil || !reports[0].Timestamp.Equal(want) {
+		t.Fatalf("unexpected report %+v", reports[0])
+	}
+
+	for _, body := range []string{`{"status":"delivered"}`, `{`, `{"provider_ref":"r1","timestamp":"yesterday"}`} {
+		if _, err := (GenericParser{}).Parse(newRequest("application/json", body)); err != ErrInvalidReport {
+			t.Errorf("%s: expected ErrInvalidReport, got %v", body, err)
+		}
+	}
+}
+
+func TestMagfaParserForm(t *testing.T) {
+	reports, err := MagfaParser{}.Parse(newRequest("application/x-www-form-urlencoded", "mid=123456&status=1&date=2024-01-01+10:00:00"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("unexpected result %v %v", reports, err)
+	}
+	r := reports[0]
+	want := time.Date(2024, 1, 1, 6, 30, 0, 0, time.UTC)
+	if r.ProviderRef != "123456" || r.Status != Delivered || r.Code != "1" || r.Timestamp == nil || !r.Timestamp.Equal(want) {
+		t.Fatalf("unexpected report %+v", r)
+	}
+}
+
+func TestMagfaParserJSON(t *testing.T) {
+	bodies := []string{
+		`{"dlrs":[{"mid":1,"status":2},{"mid":"2","status":16},{"mid":3,"status":8}]}`,
+		`[{"mid":1,"status":2},{"mid":"2","status":"16"},{"mid":3,"status":8}]`,
+	}
+	for _, body := range bodies {
+		reports, err := MagfaParser{}.Parse(newRequest("application/json", body))
+		if err != nil || len(reports) != 3 {
+			t.Fatalf("%s: unexpected result %v %v", body, reports, err)
+		}
+		if reports[0].Status != Undelivered || reports[1].Status != Rejected || reports[2].Status != Unknown {
+			t.Errorf("%s: unexpected statuses %+v", body, reports)
+		}
+		if reports[1].ProviderRef != "2" || reports[2].Code != "8" || reports[2].Final() {
+			t.Errorf("%s: unexpected reports %+v", body, reports)
+		}
+	}
+
+	reports, err := MagfaParser{}.Parse(newRequest("application/json", `{"mid":7,"status":1,"date":"2024-01-01T10:00:00Z"}`))
+	if err != nil || len(reports) != 1 || reports[0].ProviderRef != "7" || reports[0].Timestamp == nil {
+		t.Fatalf("unexpected result %v %v", reports, err)
+	}
+
+	for _, body := range []string{`{"status":1}`, `{"dlrs":[]}`, `{"mid":1}`, `[`} {
+		if _, err := (MagfaParser{}).Parse(newRequest("application/json", body)); err != ErrInvalidReport {
+			t.Errorf("%s: expected ErrInvalidReport, got %v", body, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package dlr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// genericStatuses maps the textual statuses of the generic format.
+var genericStatuses = map[string]string{
+	"delivered":   Delivered,
+	"undelivered": Undelivered,
+	"failed":      Undelivered,
+	"expired":     Expired,
+	"rejected":    Rejected,
+}
+
+// GenericParser reads the gateway's own callback format: a JSON object or a
+// form with provider_ref, a textual status and an optional RFC 3339
+// timestamp.
+type GenericParser struct{}
+
+type genericReport struct {
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// Parse implements Parser.
+func (GenericParser) Parse(r *http.Request) ([]Report, error) {
+	var in genericReport
+	if isJSON(r) {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, ErrInvalidReport
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, ErrInvalidReport
+		}
+		in = genericReport{
+			ProviderRef: r.Form.Get("provider_ref"),
+			Status:      r.Form.Get("status"),
+			Timestamp:   r.Form.Get("timestamp"),
+		}
+	}
+	if in.ProviderRef == "" {
+		return nil, ErrInvalidReport
+	}
+
+	report := Report{ProviderRef: in.ProviderRef, Status: Unknown, Code: in.Status}
+	if s, ok := genericStatuses[strings.ToLower(strings.TrimSpace(in.Status))]; ok {
+		report.Status = s
+	}
+	if in.Timestamp != "" {
+		ts, err := time.Parse(time.RFC3339, in.Timestamp)
+		if err != nil {
+			return nil, ErrInvalidReport
+		}
+		ts = ts.UTC()
+		report.Timestamp = &ts
+	}
+	return []Report{report}, nil
+}
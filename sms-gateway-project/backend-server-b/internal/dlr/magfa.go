@@ -0,0 +1,132 @@
+package dlr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// magfaStatuses maps Magfa's numeric delivery codes. 8 (delivered to the
+// operator) and 0 (no report yet) are not final and stay Unknown.
+var magfaStatuses = map[int]string{
+	1:  Delivered,
+	2:  Undelivered,
+	16: Rejected,
+}
+
+// magfaZone is the zone of Magfa's timestamps, which carry no offset.
+var magfaZone = time.FixedZone("IRST", 3*3600+1800)
+
+// MagfaParser reads Magfa delivery callbacks, either form-encoded with the
+// fields mid, status and date, or JSON holding one such object, an array of
+// them or a "dlrs" list.
+type MagfaParser struct{}
+
+type magfaReport struct {
+	MID    json.RawMessage `json:"mid"`
+	Status json.RawMessage `json:"status"`
+	Date   string          `json:"date"`
+}
+
+// Parse implements Parser.
+func (MagfaParser) Parse(r *http.Request) ([]Report, error) {
+	var in []magfaReport
+	if isJSON(r) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, ErrInvalidReport
+		}
+		if in, err = decodeMagfaJSON(body); err != nil {
+			return nil, ErrInvalidReport
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, ErrInvalidReport
+		}
+		in = []magfaReport{{
+			MID:    json.RawMessage(r.Form.Get("mid")),
+			Status: json.RawMessage(r.Form.Get("status")),
+			Date:   r.Form.Get("date"),
+		}}
+	}
+	if len(in) == 0 {
+		return nil, ErrInvalidReport
+	}
+
+	reports := make([]Report, 0, len(in))
+	for _, m := range in {
+		report, err := m.report()
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// decodeMagfaJSON accepts the single object, array and "dlrs" shapes.
+func decodeMagfaJSON(body []byte) ([]magfaReport, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var list []magfaReport
+		err := json.Unmarshal(body, &list)
+		return list, err
+	}
+	var wrapped struct {
+		DLRs []magfaReport `json:"dlrs"`
+		magfaReport
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, err
+	}
+	if wrapped.DLRs != nil {
+		return wrapped.DLRs, nil
+	}
+	return []magfaReport{wrapped.magfaReport}, nil
+}
+
+// report converts one Magfa entry into a Report.
+func (m magfaReport) report() (Report, error) {
+	ref := rawValue(m.MID)
+	code := rawValue(m.Status)
+	if ref == "" || code == "" {
+		return Report{}, ErrInvalidReport
+	}
+	report := Report{ProviderRef: ref, Status: Unknown, Code: code}
+	if n, err := strconv.Atoi(code); err == nil {
+		if s, ok := magfaStatuses[n]; ok {
+			report.Status = s
+		}
+	}
+	if m.Date != "" {
+		ts, err := parseMagfaDate(m.Date)
+		if err != nil {
+			return Report{}, ErrInvalidReport
+		}
+		report.Timestamp = &ts
+	}
+	return report, nil
+}
+
+// parseMagfaDate reads Magfa's local "2006-01-02 15:04:05" timestamps and
+// also accepts RFC 3339.
+func parseMagfaDate(s string) (time.Time, error) {
+	ts, err := time.ParseInLocation(time.DateTime, s, magfaZone)
+	if err != nil {
+		ts, err = time.Parse(time.RFC3339, s)
+	}
+	return ts.UTC(), err
+}
+
+// rawValue returns a JSON string or number as plain text.
+func rawValue(raw json.RawMessage) string {
+	v := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if v == "null" {
+		return ""
+	}
+	return v
+}
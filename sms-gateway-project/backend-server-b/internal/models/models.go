@@ -10,9 +10,76 @@ type Message struct {
 	Text        string
 	Status      string
 	ProviderRef string
+	// Encoding (GSM-7 or UCS-2) and Segments describe how Text is sent and
+	// how many SMS parts it costs.
+	Encoding string
+	Segments int
+	// BatchID links messages accepted by one bulk request; empty otherwise.
+	BatchID string `gorm:"index"`
+	// SendAt is when a SCHEDULED message is released; Payload keeps its queue
+	// message until then.
+	SendAt  *time.Time `gorm:"index"`
+	Payload string     `json:"-"`
+	// ReleaseLease is set while the scheduler publishes a due message; once it
+	// passes, an interrupted release is taken over by the next run.
+	ReleaseLease *time.Time `json:"-"`
+	// FailureCode and FailureReason record why the last send attempt failed.
+	FailureCode   string
+	FailureReason string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Events        []MessageEvent
+}
+
+// Batch groups the messages of one bulk request.
+type Batch struct {
+	ID        string `gorm:"primaryKey"`
+	Size      int
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Template is a reusable message text with one variant per language.
+type Template struct {
+	ID              string `gorm:"primaryKey"`
+	Description     string
+	DefaultLanguage string
+	Variants        []TemplateVariant `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// TemplateVariant is the body of a template in one language.
+type TemplateVariant struct {
+	ID         uint   `gorm:"primaryKey"`
+	TemplateID string `gorm:"uniqueIndex:idx_template_language"`
+	Language   string `gorm:"uniqueIndex:idx_template_language"`
+	Body       string
+}
+
+// BlocklistEntry stops messages to Phone, for every client when Client is
+// empty and only for that client otherwise.
+type BlocklistEntry struct {
+	ID        uint   `gorm:"primaryKey"`
+	Phone     string `gorm:"uniqueIndex:idx_blocklist_phone_client"`
+	Client    string `gorm:"uniqueIndex:idx_blocklist_phone_client"`
+	Reason    string
+	CreatedAt time.Time
+}
+
+// InboundMessage is a mobile-originated SMS received through a provider
+// webhook. OptOut records whether it put the sender on the blocklist.
+type InboundMessage struct {
+	ID          uint   `gorm:"primaryKey"`
+	Provider    string `gorm:"index"`
+	ProviderRef string `gorm:"index"`
+	Sender      string `gorm:"index"`
+	Receiver    string
+	Text        string
+	OptOut      bool
+	ReceivedAt  time.Time
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Events      []MessageEvent
 }
 
 // MessageEvent stores a historical event for a message.
@@ -21,7 +88,11 @@ type MessageEvent struct {
 	MessageID uint    `gorm:"index"`
 	Message   Message `gorm:"constraint:OnDelete:CASCADE"`
 	Event     string
-	CreatedAt time.Time
+	// ProviderCode and OccurredAt are the raw status code and the time a
+	// provider reported in a delivery report.
+	ProviderCode string
+	OccurredAt   *time.Time
+	CreatedAt    time.Time
 }
 
 // UIUser represents a web panel user.
@@ -38,3 +109,41 @@ type UIUser struct {
 	IsActive   bool
 	IsAdmin    bool
 }
+
+// Provider mirrors a row of the sms_providers table, which is owned by the
+// provider admin service's Prisma migrations.
+type Provider struct {
+	ID                      string `gorm:"primaryKey"`
+	Name                    string `gorm:"uniqueIndex"`
+	Type                    string
+	BaseURL                 string
+	EndpointPath            string
+	AuthType                string
+	BasicUsername           *string
+	BasicPasswordCiphertext *string
+	DefaultSender           *string
+	ExtraHeadersJSON        *string
+	TimeoutMs               int
+	Retries                 int
+	RetryBackoffMs          int
+	Priority                int
+	Weight                  int
+	MaxConcurrency          int
+	IsEnabled               bool
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+}
+
+// TableName maps Provider to the Prisma-managed table.
+func (Provider) TableName() string { return "sms_providers" }
+
+// DeadLetter stores a message that was moved to the dead-letter queue.
+type DeadLetter struct {
+	ID         uint   `gorm:"primaryKey"`
+	TrackingID string `gorm:"index"`
+	Body       string
+	Headers    string
+	Reason     string
+	Attempts   int
+	CreatedAt  time.Time
+}
@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SendError describes why a provider did not accept a message.
+type SendError struct {
+	// Provider is the name of the provider that failed.
+	Provider string
+	// Code is the provider specific error code, if any.
+	Code string
+	// Message is a human readable description of Code.
+	Message string
+	// HTTPStatus is the status of the provider's HTTP response, if any.
+	HTTPStatus int
+	// Raw is the provider's raw response body.
+	Raw string
+	// Retryable marks transient failures that may succeed when sent again
+	// through the same provider.
+	Retryable bool
+	// Permanent marks rejections of the message itself, such as an invalid
+	// recipient, which no other provider would accept either.
+	Permanent bool
+	// Err is the underlying transport or decoding error, if any.
+	Err error
+}
+
+func (e *SendError) Error() string {
+	msg := e.Provider
+	if e.Code != "" {
+		msg += ": code " + e.Code
+	}
+	if e.Message != "" {
+		msg += " (" + e.Message + ")"
+	}
+	if e.HTTPStatus != 0 {
+		msg += fmt.Sprintf(": http status %d", e.HTTPStatus)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *SendError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err is a transient failure that may succeed
+// when sent again through the same provider.
+func IsRetryable(err error) bool {
+	var serr *SendError
+	return errors.As(err, &serr) && serr.Retryable
+}
+
+// IsPermanent reports whether err rejects the message itself, so that
+// failing over to another provider is pointless.
+func IsPermanent(err error) bool {
+	var serr *SendError
+	return errors.As(err, &serr) && serr.Permanent
+}
@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSendErrorClassification(t *testing.T) {
+	retryable := fmt.Errorf("wrapped: %w", &SendError{Provider: "p", HTTPStatus: 503, Retryable: true})
+	if !IsRetryable(retryable) || IsPermanent(retryable) {
+		t.Fatal("expected wrapped retryable error")
+	}
+	permanent := &SendError{Provider: "p", Code: "1", Permanent: true}
+	if IsRetryable(permanent) || !IsPermanent(permanent) {
+		t.Fatal("expected permanent error")
+	}
+	if IsRetryable(errors.New("plain")) || IsPermanent(errors.New("plain")) {
+		t.Fatal("plain errors are neither retryable nor permanent")
+	}
+}
+
+func TestSendErrorMessage(t *testing.T) {
+	err := &SendError{Provider: "magfa", Code: "18", Message: "invalid credentials", HTTPStatus: 200}
+	if got, want := err.Error(), "magfa: code 18 (invalid credentials): http status 200"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
@@ -1,14 +1,25 @@
 package providers
 
-import "sms-gateway/backend-server-b/internal/config"
+import (
+	"strings"
+
+	"sms-gateway/backend-server-b/internal/config"
+)
 
 // GetProvider returns an SmsProvider based on the given name and configuration.
+// The configuration's type takes precedence over the name when set.
 func GetProvider(name string, cfg config.ProviderConfig) SmsProvider {
-	switch name {
-	case "Provider-A":
+	kind := cfg.Type
+	if kind == "" {
+		kind = name
+	}
+	switch strings.ToLower(kind) {
+	case "provider-a":
 		return NewProviderA(cfg)
-	case "Provider-B":
+	case "provider-b":
 		return NewProviderB(cfg)
+	case "magfa":
+		return NewMagfaProvider(name, cfg)
 	default:
 		return nil
 	}
@@ -1,9 +1,14 @@
 package providers
 
-import "sms-gateway/backend-server-b/internal/models"
+import (
+	"context"
 
-// SmsProvider defines an interface for sending SMS messages.
+	"sms-gateway/backend-server-b/internal/models"
+)
+
+// SmsProvider defines an interface for sending SMS messages. Send must give
+// up and return once ctx is done.
 type SmsProvider interface {
-	Send(message models.Message) (string, error)
+	Send(ctx context.Context, message models.Message) (string, error)
 	GetName() string
 }
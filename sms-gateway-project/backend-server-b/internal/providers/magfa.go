@@ -0,0 +1,174 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sms-gateway/backend-server-b/internal/config"
+	"sms-gateway/backend-server-b/internal/models"
+)
+
+// DefaultMagfaURL is the Magfa v2 HTTP send endpoint.
+const DefaultMagfaURL = "https://sms.magfa.com/api/http/sms/v2/send"
+
+// magfaErrors describes the Magfa status codes the gateway acts upon.
+var magfaErrors = map[int]struct {
+	message   string
+	retryable bool
+	permanent bool
+}{
+	1:  {message: "invalid recipient number", permanent: true},
+	2:  {message: "invalid sender number"},
+	3:  {message: "invalid encoding", permanent: true},
+	13: {message: "empty message", permanent: true},
+	14: {message: "insufficient credit"},
+	15: {message: "server busy", retryable: true},
+	16: {message: "account inactive"},
+	17: {message: "account expired"},
+	18: {message: "invalid username, password or domain"},
+	19: {message: "authentication failed"},
+	27: {message: "recipient is blacklisted", permanent: true},
+}
+
+// MagfaProvider implements SmsProvider for the Magfa HTTP API.
+type MagfaProvider struct {
+	name   string
+	cfg    config.ProviderConfig
+	client *http.Client
+}
+
+// NewMagfaProvider creates a new MagfaProvider. Request deadlines come from
+// the context passed to Send.
+func NewMagfaProvider(name string, cfg config.ProviderConfig) *MagfaProvider {
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultMagfaURL
+	}
+	return &MagfaProvider{name: name, cfg: cfg, client: &http.Client{}}
+}
+
+// GetName returns the provider's name.
+func (p *MagfaProvider) GetName() string { return p.name }
+
+type magfaRequest struct {
+	Senders    []string `json:"senders"`
+	Messages   []string `json:"messages"`
+	Recipients []string `json:"recipients"`
+}
+
+type magfaResponse struct {
+	Status   int `json:"status"`
+	Messages []struct {
+		Status    int             `json:"status"`
+		ID        json.RawMessage `json:"id"`
+		Recipient string          `json:"recipient"`
+	} `json:"messages"`
+	IDs []json.RawMessage `json:"ids"`
+	ID  json.RawMessage   `json:"id"`
+}
+
+// Send sends an SMS message via Magfa and returns Magfa's message id.
+func (p *MagfaProvider) Send(ctx context.Context, message models.Message) (string, error) {
+	// Magfa takes international numbers without the leading "+"
+	recipient := strings.TrimPrefix(message.Recipient, "+")
+	body, err := json.Marshal(magfaRequest{
+		Senders:    []string{p.cfg.Sender},
+		Messages:   []string{message.Text},
+		Recipients: []string{recipient},
+	})
+	if err != nil {
+		return "", &SendError{Provider: p.name, Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return "", &SendError{Provider: p.name, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Cache-Control", "no-cache")
+	for k, v := range p.cfg.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+	req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// network failures and timeouts are worth another try, unless the
+		// caller gave up on the message altogether
+		return "", &SendError{Provider: p.name, Retryable: !errors.Is(err, context.Canceled), Err: err}
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", &SendError{Provider: p.name, HTTPStatus: resp.StatusCode, Retryable: true, Err: err}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &SendError{
+			Provider:   p.name,
+			HTTPStatus: resp.StatusCode,
+			Raw:        string(raw),
+			Retryable:  isRetryableStatus(resp.StatusCode),
+		}
+	}
+
+	var out magfaResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", &SendError{Provider: p.name, HTTPStatus: resp.StatusCode, Raw: string(raw), Err: err}
+	}
+	if out.Status != 0 {
+		return "", p.statusError(out.Status, resp.StatusCode, raw)
+	}
+
+	var ids []string
+	for _, m := range out.Messages {
+		if m.Status != 0 {
+			return "", p.statusError(m.Status, resp.StatusCode, raw)
+		}
+		ids = appendRef(ids, m.ID)
+	}
+	for _, id := range out.IDs {
+		ids = appendRef(ids, id)
+	}
+	ids = appendRef(ids, out.ID)
+	if len(ids) == 0 {
+		// without a reference no delivery report could ever be matched
+		return "", &SendError{Provider: p.name, Message: "response carried no message id", HTTPStatus: resp.StatusCode, Raw: string(raw)}
+	}
+
+	return strings.Join(ids, ","), nil
+}
+
+// statusError converts a non-zero Magfa status code into a SendError.
+func (p *MagfaProvider) statusError(code, httpStatus int, raw []byte) *SendError {
+	info := magfaErrors[code]
+	return &SendError{
+		Provider:   p.name,
+		Code:       strconv.Itoa(code),
+		Message:    info.message,
+		HTTPStatus: httpStatus,
+		Raw:        string(raw),
+		Retryable:  info.retryable,
+		Permanent:  info.permanent,
+	}
+}
+
+// isRetryableStatus reports whether an HTTP status indicates a transient failure.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || (status >= 500 && status < 600)
+}
+
+// appendRef appends a JSON string or number id to refs, skipping empty values.
+func appendRef(refs []string, raw json.RawMessage) []string {
+	v := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if v == "" || v == "null" {
+		return refs
+	}
+	return append(refs, v)
+}
@@ -0,0 +1,183 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sms-gateway/backend-server-b/internal/config"
+	"sms-gateway/backend-server-b/internal/models"
+)
+
+func newMagfaTestProvider(url string) *MagfaProvider {
+	return NewMagfaProvider("magfa", config.ProviderConfig{
+		Type:     "magfa",
+		APIURL:   url,
+		Username: "user/domain",
+		Password: "pass",
+		Sender:   "3000",
+	})
+}
+
+func TestMagfaSendSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user/domain" || pass != "pass" {
+			t.Errorf("unexpected basic auth: %q %q %v", user, pass, ok)
+		}
+		var body magfaRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(body.Senders) != 1 || body.Senders[0] != "3000" {
+			t.Errorf("unexpected senders: %v", body.Senders)
+		}
+		if len(body.Messages) != 1 || body.Messages[0] != "hello" {
+			t.Errorf("unexpected messages: %v", body.Messages)
+		}
+		if len(body.Recipients) != 1 || body.Recipients[0] != "09120000000" {
+			t.Errorf("unexpected recipients: %v", body.Recipients)
+		}
+		w.Write([]byte(`{"status":0,"messages":[{"status":0,"id":123456,"recipient":"09120000000"}]}`))
+	}))
+	defer srv.Close()
+
+	ref, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "09120000000", Text: "hello"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if ref != "123456" {
+		t.Fatalf("expected provider ref 123456, got %q", ref)
+	}
+}
+
+func TestMagfaSendStripsPlus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body magfaRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Recipients) != 1 || body.Recipients[0] != "989120000000" {
+			t.Errorf("unexpected recipients: %v", body.Recipients)
+		}
+		w.Write([]byte(`{"status":0,"messages":[{"status":0,"id":1}]}`))
+	}))
+	defer srv.Close()
+
+	if _, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "+989120000000", Text: "m"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func TestMagfaSendParsesIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ids":["1"]}`))
+	}))
+	defer srv.Close()
+
+	ref, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "r1", Text: "m"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if ref != "1" {
+		t.Fatalf("expected provider ref 1, got %q", ref)
+	}
+}
+
+func TestMagfaSendWithoutID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":0,"messages":[]}`))
+	}))
+	defer srv.Close()
+
+	ref, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "r1", Text: "m"})
+	var serr *SendError
+	if !errors.As(err, &serr) || ref != "" {
+		t.Fatalf("expected SendError, got %q %v", ref, err)
+	}
+	if serr.Raw == "" || serr.Retryable || serr.Permanent {
+		t.Fatalf("unexpected error: %+v", serr)
+	}
+}
+
+func TestMagfaSendRetryableStatuses(t *testing.T) {
+	cases := map[int]bool{
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+	}
+	for status, retryable := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		_, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "r1", Text: "m"})
+		srv.Close()
+
+		var serr *SendError
+		if !errors.As(err, &serr) {
+			t.Fatalf("status %d: expected SendError, got %v", status, err)
+		}
+		if serr.HTTPStatus != status || serr.Retryable != retryable {
+			t.Errorf("status %d: got http=%d retryable=%v", status, serr.HTTPStatus, serr.Retryable)
+		}
+	}
+}
+
+func TestMagfaSendErrorCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":18,"messages":null}`))
+	}))
+	defer srv.Close()
+
+	_, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "r1", Text: "m"})
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected SendError, got %v", err)
+	}
+	if serr.Code != "18" || serr.Retryable || serr.Permanent || serr.Raw == "" {
+		t.Fatalf("unexpected error: %+v", serr)
+	}
+}
+
+func TestMagfaSendPermanentRejection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":0,"messages":[{"status":1,"id":null,"recipient":"bad"}]}`))
+	}))
+	defer srv.Close()
+
+	_, err := newMagfaTestProvider(srv.URL).Send(context.Background(), models.Message{Recipient: "bad", Text: "m"})
+	if !IsPermanent(err) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+}
+
+func TestGetProviderMagfa(t *testing.T) {
+	p := GetProvider("magfa-prod", config.ProviderConfig{Type: "magfa"})
+	if _, ok := p.(*MagfaProvider); !ok {
+		t.Fatalf("expected MagfaProvider, got %T", p)
+	}
+	if p.GetName() != "magfa-prod" {
+		t.Fatalf("expected name magfa-prod, got %s", p.GetName())
+	}
+}
+
+func TestMagfaSendHonorsContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := newMagfaTestProvider(srv.URL).Send(ctx, models.Message{Recipient: "r1", Text: "m"})
+	if !errors.Is(err, context.DeadlineExceeded) || !IsRetryable(err) {
+		t.Fatalf("expected retryable deadline error, got %v", err)
+	}
+}
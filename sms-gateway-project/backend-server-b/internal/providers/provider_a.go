@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 	"sms-gateway/backend-server-b/internal/config"
 	"sms-gateway/backend-server-b/internal/models"
@@ -20,6 +22,9 @@ func NewProviderA(cfg config.ProviderConfig) *ProviderAAdapter {
 func (p *ProviderAAdapter) GetName() string { return "Provider-A" }
 
 // Send sends an SMS message via Provider-A.
-func (p *ProviderAAdapter) Send(message models.Message) (string, error) {
+func (p *ProviderAAdapter) Send(ctx context.Context, message models.Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	return uuid.NewString(), nil
 }
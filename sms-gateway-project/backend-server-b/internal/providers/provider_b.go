@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 	"sms-gateway/backend-server-b/internal/config"
 	"sms-gateway/backend-server-b/internal/models"
@@ -20,6 +22,9 @@ func NewProviderB(cfg config.ProviderConfig) *ProviderBAdapter {
 func (p *ProviderBAdapter) GetName() string { return "Provider-B" }
 
 // Send sends an SMS message via Provider-B.
-func (p *ProviderBAdapter) Send(message models.Message) (string, error) {
+func (p *ProviderBAdapter) Send(ctx context.Context, message models.Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	return uuid.NewString(), nil
 }
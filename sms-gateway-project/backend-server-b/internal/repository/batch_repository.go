@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Batch statuses.
+const (
+	BatchActive    = "ACTIVE"
+	BatchCancelled = "CANCELLED"
+)
+
+// BatchRepository provides database operations for message batches.
+type BatchRepository struct {
+	DB *gorm.DB
+}
+
+// NewBatchRepository creates a new repository instance for batches.
+func NewBatchRepository(db *gorm.DB) *BatchRepository {
+	return &BatchRepository{DB: db}
+}
+
+// EnsureBatch creates an active batch unless it already exists.
+func (r *BatchRepository) EnsureBatch(id string, size int) error {
+	batch := models.Batch{ID: id, Size: size, Status: BatchActive}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch).Error
+}
+
+// GetBatch retrieves a batch by ID.
+func (r *BatchRepository) GetBatch(id string) (models.Batch, error) {
+	var batch models.Batch
+	err := r.DB.Where("id = ?", id).First(&batch).Error
+	return batch, err
+}
+
+// IsCancelled reports whether the batch has been cancelled.
+func (r *BatchRepository) IsCancelled(id string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.Batch{}).Where("id = ? AND status = ?", id, BatchCancelled).Count(&count).Error
+	return count > 0, err
+}
+
+// CountByStatus returns the number of the batch's messages in each status.
+func (r *BatchRepository) CountByStatus(id string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.DB.Model(&models.Message{}).Select("status, count(*) as count").
+		Where("batch_id = ?", id).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// waitingStatuses are the statuses of messages that were not processed yet.
+var waitingStatuses = []string{"QUEUED", "SCHEDULED"}
+
+// CancelBatch marks the batch cancelled and cancels its messages that are
+// still waiting to be processed, whether queued or scheduled. It returns the
+// number of messages cancelled. Messages of the batch not consumed yet are
+// cancelled when they arrive.
+func (r *BatchRepository) CancelBatch(id string) (int64, error) {
+	var cancelled int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Batch{}).Where("id = ?", id).Update("status", BatchCancelled).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Model(&models.Message{}).Where("batch_id = ? AND status IN ?", id, waitingStatuses).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		res := tx.Model(&models.Message{}).Where("id IN ? AND status IN ?", ids, waitingStatuses).Update("status", "CANCELLED")
+		if res.Error != nil {
+			return res.Error
+		}
+		cancelled = res.RowsAffected
+		events := make([]models.MessageEvent, 0, len(ids))
+		for _, msgID := range ids {
+			events = append(events, models.MessageEvent{MessageID: msgID, Event: "cancelled with batch"})
+		}
+		return tx.Create(&events).Error
+	})
+	return cancelled, err
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlocklistRepository provides database operations for the blocklist.
+type BlocklistRepository struct {
+	DB *gorm.DB
+}
+
+// NewBlocklistRepository creates a new repository instance for the blocklist.
+func NewBlocklistRepository(db *gorm.DB) *BlocklistRepository {
+	return &BlocklistRepository{DB: db}
+}
+
+// FindBlock returns the entry blocking phone for client, preferring a global
+// entry, and whether there is one. It runs for every message, so a miss is
+// not treated as an error.
+func (r *BlocklistRepository) FindBlock(phone, client string) (models.BlocklistEntry, bool, error) {
+	var entries []models.BlocklistEntry
+	err := r.DB.Where("phone = ? AND (client = '' OR client = ?)", phone, client).Order("client").Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return models.BlocklistEntry{}, false, err
+	}
+	return entries[0], true, nil
+}
+
+// AddEntries inserts entries, skipping those already on the blocklist, and
+// returns how many were added.
+func (r *BlocklistRepository) AddEntries(entries []models.BlocklistEntry) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	res := r.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&entries, 500)
+	return res.RowsAffected, res.Error
+}
+
+// GetEntries returns a page of entries, newest first, and the total count.
+// A non-empty client limits the result to that client's entries.
+func (r *BlocklistRepository) GetEntries(client string, limit, offset int) ([]models.BlocklistEntry, int64, error) {
+	var items []models.BlocklistEntry
+	var total int64
+	q := r.DB.Model(&models.BlocklistEntry{})
+	if client != "" {
+		q = q.Where("client = ?", client)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("id desc").Limit(limit).Offset(offset).Find(&items).Error
+	return items, total, err
+}
+
+// DeleteEntry removes an entry by ID and reports whether it existed.
+func (r *BlocklistRepository) DeleteEntry(id uint) (bool, error) {
+	res := r.DB.Delete(&models.BlocklistEntry{}, id)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DeadLetterRepository provides database operations for dead-lettered messages.
+type DeadLetterRepository struct {
+	DB *gorm.DB
+}
+
+// NewDeadLetterRepository creates a new repository instance for dead letters.
+func NewDeadLetterRepository(db *gorm.DB) *DeadLetterRepository {
+	return &DeadLetterRepository{DB: db}
+}
+
+// CreateDeadLetter stores a dead-lettered message.
+func (r *DeadLetterRepository) CreateDeadLetter(dl *models.DeadLetter) error {
+	return r.DB.Create(dl).Error
+}
+
+// GetDeadLetters returns a page of dead letters, newest first, and the total count.
+func (r *DeadLetterRepository) GetDeadLetters(limit, offset int) ([]models.DeadLetter, int64, error) {
+	var items []models.DeadLetter
+	var total int64
+	if err := r.DB.Model(&models.DeadLetter{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.DB.Order("id desc").Limit(limit).Offset(offset).Find(&items).Error
+	return items, total, err
+}
+
+// GetDeadLetterByID retrieves a dead letter by ID.
+func (r *DeadLetterRepository) GetDeadLetterByID(id uint) (models.DeadLetter, error) {
+	var dl models.DeadLetter
+	err := r.DB.First(&dl, id).Error
+	return dl, err
+}
+
+// DeleteDeadLetter removes a dead letter by ID.
+func (r *DeadLetterRepository) DeleteDeadLetter(id uint) error {
+	return r.DB.Delete(&models.DeadLetter{}, id).Error
+}
+
+// PurgeDeadLetters removes all dead letters and returns how many were deleted.
+func (r *DeadLetterRepository) PurgeDeadLetters() (int64, error) {
+	res := r.DB.Where("1 = 1").Delete(&models.DeadLetter{})
+	return res.RowsAffected, res.Error
+}
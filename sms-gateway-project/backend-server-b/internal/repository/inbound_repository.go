@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InboundRepository provides database operations for inbound messages.
+type InboundRepository struct {
+	DB *gorm.DB
+}
+
+// NewInboundRepository creates a new repository instance for inbound messages.
+func NewInboundRepository(db *gorm.DB) *InboundRepository {
+	return &InboundRepository{DB: db}
+}
+
+// CreateInboundMessage stores a received message.
+func (r *InboundRepository) CreateInboundMessage(msg *models.InboundMessage) error {
+	return r.DB.Create(msg).Error
+}
+
+// Exists reports whether the provider already delivered the message with
+// the given reference, so retried callbacks are stored once.
+func (r *InboundRepository) Exists(provider, providerRef string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.InboundMessage{}).Where("provider = ? AND provider_ref = ?", provider, providerRef).Count(&count).Error
+	return count > 0, err
+}
+
+// GetInboundMessages returns a page of inbound messages, newest first, and
+// the total count. A non-empty sender limits the result to that sender.
+func (r *InboundRepository) GetInboundMessages(sender string, limit, offset int) ([]models.InboundMessage, int64, error) {
+	var items []models.InboundMessage
+	var total int64
+	q := r.DB.Model(&models.InboundMessage{})
+	if sender != "" {
+		q = q.Where("sender = ?", sender)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("received_at desc, id desc").Limit(limit).Offset(offset).Find(&items).Error
+	return items, total, err
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"time"
+
 	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/shared/smsenc"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MessageRepository provides database operations for messages.
@@ -16,12 +20,111 @@ func NewMessageRepository(db *gorm.DB) *MessageRepository {
 	return &MessageRepository{DB: db}
 }
 
+// newQueuedMessage builds a QUEUED message together with the encoding and
+// segment count of its text.
+func newQueuedMessage(trackingID, recipient, text, batchID string) models.Message {
+	info := smsenc.Analyze(text)
+	return models.Message{
+		TrackingID: trackingID,
+		Recipient:  recipient,
+		Text:       text,
+		BatchID:    batchID,
+		Status:     "QUEUED",
+		Encoding:   string(info.Encoding),
+		Segments:   info.Segments,
+	}
+}
+
 // CreateInitialMessage inserts a new message with QUEUED status.
 func (r *MessageRepository) CreateInitialMessage(trackingID, recipient, text string) error {
-	msg := models.Message{TrackingID: trackingID, Recipient: recipient, Text: text, Status: "QUEUED"}
+	msg := newQueuedMessage(trackingID, recipient, text, "")
 	return r.DB.Create(&msg).Error
 }
 
+// EnsureMessage inserts a QUEUED message unless one with trackingID already
+// exists, and reports whether it inserted the row. Redeliveries of the same
+// message therefore leave the existing row untouched.
+func (r *MessageRepository) EnsureMessage(trackingID, recipient, text, batchID string) (bool, error) {
+	msg := newQueuedMessage(trackingID, recipient, text, batchID)
+	res := r.DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "tracking_id"}}, DoNothing: true}).Create(&msg)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ScheduleMessage holds a QUEUED message until sendAt, keeping its queue
+// payload for release. It reports whether the message was scheduled.
+func (r *MessageRepository) ScheduleMessage(trackingID string, sendAt time.Time, payload string) (bool, error) {
+	res := r.DB.Model(&models.Message{}).Where("tracking_id = ? AND status = ?", trackingID, "QUEUED").Updates(map[string]any{
+		"status":  "SCHEDULED",
+		"send_at": sendAt,
+		"payload": payload,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ClaimDueMessages leases up to limit SCHEDULED messages whose send time has
+// come and returns them. The messages stay SCHEDULED until MarkReleased, so
+// one whose release is interrupted, even by a crash, is claimed again once
+// its lease has expired. Each row is claimed with a conditional update, so
+// concurrent schedulers never release a message twice while a lease holds.
+func (r *MessageRepository) ClaimDueMessages(now time.Time, lease time.Duration, limit int) ([]models.Message, error) {
+	var due []models.Message
+	err := r.DB.Where("status = ? AND send_at <= ? AND (release_lease IS NULL OR release_lease <= ?)", "SCHEDULED", now, now).
+		Order("send_at").Limit(limit).Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+	until := now.Add(lease)
+	claimed := due[:0]
+	for _, msg := range due {
+		res := r.DB.Model(&models.Message{}).
+			Where("id = ? AND status = ? AND (release_lease IS NULL OR release_lease <= ?)", msg.ID, "SCHEDULED", now).
+			Update("release_lease", until)
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			msg.ReleaseLease = &until
+			claimed = append(claimed, msg)
+		}
+	}
+	return claimed, nil
+}
+
+// MarkReleased moves a claimed message that was published back to QUEUED. A
+// message the consumer already picked up keeps its newer status.
+func (r *MessageRepository) MarkReleased(trackingID string) error {
+	return r.DB.Model(&models.Message{}).Where("tracking_id = ? AND status = ?", trackingID, "SCHEDULED").Updates(map[string]any{
+		"status":        "QUEUED",
+		"release_lease": nil,
+	}).Error
+}
+
+// UnclaimMessage drops the lease of a claimed message after its release
+// failed, so the next run tries it again.
+func (r *MessageRepository) UnclaimMessage(trackingID string) error {
+	return r.DB.Model(&models.Message{}).Where("tracking_id = ? AND status = ?", trackingID, "SCHEDULED").Update("release_lease", nil).Error
+}
+
+// GetScheduledMessages returns a page of SCHEDULED messages, soonest first,
+// and their total count.
+func (r *MessageRepository) GetScheduledMessages(limit, offset int) ([]models.Message, int64, error) {
+	var messages []models.Message
+	var total int64
+	q := r.DB.Model(&models.Message{}).Where("status = ?", "SCHEDULED")
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("send_at").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
+
+// CancelScheduledMessage cancels a message that is still SCHEDULED and
+// reports whether it did.
+func (r *MessageRepository) CancelScheduledMessage(trackingID string) (bool, error) {
+	res := r.DB.Model(&models.Message{}).Where("tracking_id = ? AND status = ?", trackingID, "SCHEDULED").Update("status", "CANCELLED")
+	return res.RowsAffected > 0, res.Error
+}
+
 // UpdateMessageStatus updates the status and provider reference of a message.
 func (r *MessageRepository) UpdateMessageStatus(trackingID, newStatus, providerRef string) error {
 	return r.DB.Model(&models.Message{}).Where("tracking_id = ?", trackingID).Updates(map[string]any{
@@ -30,6 +133,26 @@ func (r *MessageRepository) UpdateMessageStatus(trackingID, newStatus, providerR
 	}).Error
 }
 
+// MarkMessageSent records a successful hand-off to a provider and clears any
+// earlier failure.
+func (r *MessageRepository) MarkMessageSent(trackingID, providerRef string) error {
+	return r.DB.Model(&models.Message{}).Where("tracking_id = ?", trackingID).Updates(map[string]any{
+		"status":         "SENT",
+		"provider_ref":   providerRef,
+		"failure_code":   "",
+		"failure_reason": "",
+	}).Error
+}
+
+// UpdateMessageFailure sets a failure status together with its cause.
+func (r *MessageRepository) UpdateMessageFailure(trackingID, newStatus, code, reason string) error {
+	return r.DB.Model(&models.Message{}).Where("tracking_id = ?", trackingID).Updates(map[string]any{
+		"status":         newStatus,
+		"failure_code":   code,
+		"failure_reason": reason,
+	}).Error
+}
+
 // GetMessageByTrackingID retrieves a message and its events.
 func (r *MessageRepository) GetMessageByTrackingID(trackingID string) (models.Message, error) {
 	var msg models.Message
@@ -54,6 +177,17 @@ func (r *MessageRepository) CreateMessageEvent(trackingID, description string) e
 	return r.DB.Create(&event).Error
 }
 
+// CreateDeliveryEvent adds a delivery report to the message history together
+// with the provider's raw status code and report time.
+func (r *MessageRepository) CreateDeliveryEvent(messageID uint, description, code string, occurredAt *time.Time) error {
+	event := models.MessageEvent{MessageID: messageID, Event: description, ProviderCode: code, OccurredAt: occurredAt}
+	return r.DB.Create(&event).Error
+}
+
+// failedDeliveryStatuses are the delivery report statuses of messages that
+// did not reach the recipient.
+var failedDeliveryStatuses = []string{"UNDELIVERED", "REJECTED", "EXPIRED"}
+
 // DashboardStats represents summary statistics for the dashboard.
 
 type DashboardStats struct {
@@ -83,8 +217,8 @@ func (r *MessageRepository) GetDashboardStats() (DashboardStats, error) {
 		return stats, err
 	}
 
-	// Failed messages
-	if err := r.DB.Model(&models.Message{}).Where("status LIKE ?", "FAILED%").Count(&stats.Failed).Error; err != nil {
+	// Failed messages, whether sending failed or the provider reported a failed delivery
+	if err := r.DB.Model(&models.Message{}).Where("status LIKE ? OR status IN ?", "FAILED%", failedDeliveryStatuses).Count(&stats.Failed).Error; err != nil {
 		return stats, err
 	}
 
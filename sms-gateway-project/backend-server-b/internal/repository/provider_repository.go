@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ProviderRepository provides read access to configured SMS providers.
+type ProviderRepository struct {
+	DB *gorm.DB
+}
+
+// NewProviderRepository creates a new repository instance for providers.
+func NewProviderRepository(db *gorm.DB) *ProviderRepository {
+	return &ProviderRepository{DB: db}
+}
+
+// GetAllProviders retrieves every provider row, enabled or not.
+func (r *ProviderRepository) GetAllProviders() ([]models.Provider, error) {
+	var provs []models.Provider
+	err := r.DB.Order("priority asc, name asc").Find(&provs).Error
+	return provs, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"sms-gateway/backend-server-b/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TemplateRepository provides database operations for message templates.
+type TemplateRepository struct {
+	DB *gorm.DB
+}
+
+// NewTemplateRepository creates a new repository instance for templates.
+func NewTemplateRepository(db *gorm.DB) *TemplateRepository {
+	return &TemplateRepository{DB: db}
+}
+
+// CreateTemplate inserts a template together with its variants.
+func (r *TemplateRepository) CreateTemplate(tpl *models.Template) error {
+	return r.DB.Create(tpl).Error
+}
+
+// GetTemplate retrieves a template and its variants by ID.
+func (r *TemplateRepository) GetTemplate(id string) (models.Template, error) {
+	var tpl models.Template
+	err := r.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("language")
+	}).Where("id = ?", id).First(&tpl).Error
+	return tpl, err
+}
+
+// ListTemplates returns all templates with their variants, ordered by ID.
+func (r *TemplateRepository) ListTemplates() ([]models.Template, error) {
+	var tpls []models.Template
+	err := r.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("language")
+	}).Order("id").Find(&tpls).Error
+	return tpls, err
+}
+
+// UpdateTemplate replaces a template's fields and variants. It returns
+// gorm.ErrRecordNotFound when the template does not exist.
+func (r *TemplateRepository) UpdateTemplate(tpl *models.Template) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Template{ID: tpl.ID}).Select("description", "default_language", "updated_at").Updates(tpl)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.TemplateVariant{}).Error; err != nil {
+			return err
+		}
+		for i := range tpl.Variants {
+			tpl.Variants[i].ID = 0
+			tpl.Variants[i].TemplateID = tpl.ID
+		}
+		return tx.Create(&tpl.Variants).Error
+	})
+}
+
+// DeleteTemplate removes a template and its variants and reports whether it existed.
+func (r *TemplateRepository) DeleteTemplate(id string) (bool, error) {
+	deleted := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", id).Delete(&models.TemplateVariant{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&models.Template{})
+		deleted = res.RowsAffected > 0
+		return res.Error
+	})
+	return deleted, err
+}
@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Candidate describes a provider that may be chosen for a message.
+type Candidate struct {
+	Name     string
+	Priority int
+	Weight   int
+	Enabled  bool
+}
+
+// Router orders providers for delivery attempts.
+type Router struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRouter creates a Router drawing weighted choices from src.
+func NewRouter(src rand.Source) *Router {
+	return &Router{rnd: rand.New(src)}
+}
+
+// Order returns the names of the enabled candidates in the order they should
+// be tried. Lower priorities come first; candidates sharing a priority are
+// shuffled with probability proportional to their weight, so traffic is split
+// between them according to their weights. Weights below 1 count as 1.
+func (r *Router) Order(cands []Candidate) []string {
+	enabled := make([]Candidate, 0, len(cands))
+	for _, c := range cands {
+		if c.Enabled {
+			enabled = append(enabled, c)
+		}
+	}
+	sort.Slice(enabled, func(i, j int) bool {
+		if enabled[i].Priority != enabled[j].Priority {
+			return enabled[i].Priority < enabled[j].Priority
+		}
+		return enabled[i].Name < enabled[j].Name
+	})
+
+	order := make([]string, 0, len(enabled))
+	for start := 0; start < len(enabled); {
+		end := start + 1
+		for end < len(enabled) && enabled[end].Priority == enabled[start].Priority {
+			end++
+		}
+		order = append(order, r.shuffle(enabled[start:end])...)
+		start = end
+	}
+	return order
+}
+
+// shuffle draws a weighted random permutation of group.
+func (r *Router) shuffle(group []Candidate) []string {
+	if len(group) == 1 {
+		return []string{group[0].Name}
+	}
+	remaining := append([]Candidate(nil), group...)
+	out := make([]string, 0, len(group))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(remaining) > 0 {
+		total := 0
+		for _, c := range remaining {
+			total += weight(c)
+		}
+		pick := r.rnd.Intn(total)
+		idx := 0
+		for i, c := range remaining {
+			if pick < weight(c) {
+				idx = i
+				break
+			}
+			pick -= weight(c)
+		}
+		out = append(out, remaining[idx].Name)
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+	}
+	return out
+}
+
+func weight(c Candidate) int {
+	if c.Weight < 1 {
+		return 1
+	}
+	return c.Weight
+}
@@ -0,0 +1,69 @@
+package routing
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOrderByPriority(t *testing.T) {
+	r := NewRouter(rand.NewSource(1))
+	got := r.Order([]Candidate{
+		{Name: "c", Priority: 30, Enabled: true},
+		{Name: "a", Priority: 10, Enabled: true},
+		{Name: "b", Priority: 20, Enabled: true},
+	})
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestOrderSkipsDisabled(t *testing.T) {
+	r := NewRouter(rand.NewSource(1))
+	got := r.Order([]Candidate{
+		{Name: "a", Priority: 10, Enabled: false},
+		{Name: "b", Priority: 20, Enabled: true},
+	})
+	want := []string{"b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestOrderIsDeterministicForSeed(t *testing.T) {
+	cands := []Candidate{
+		{Name: "a", Priority: 10, Weight: 1, Enabled: true},
+		{Name: "b", Priority: 10, Weight: 1, Enabled: true},
+		{Name: "c", Priority: 10, Weight: 1, Enabled: true},
+		{Name: "d", Priority: 20, Weight: 1, Enabled: true},
+	}
+	r1 := NewRouter(rand.NewSource(42))
+	r2 := NewRouter(rand.NewSource(42))
+	for i := 0; i < 20; i++ {
+		o1, o2 := r1.Order(cands), r2.Order(cands)
+		if !reflect.DeepEqual(o1, o2) {
+			t.Fatalf("iteration %d: %v != %v", i, o1, o2)
+		}
+		if o1[3] != "d" {
+			t.Fatalf("lower priority provider must come last, got %v", o1)
+		}
+	}
+}
+
+func TestOrderWeightedSplit(t *testing.T) {
+	r := NewRouter(rand.NewSource(7))
+	cands := []Candidate{
+		{Name: "heavy", Priority: 10, Weight: 3, Enabled: true},
+		{Name: "light", Priority: 10, Weight: 1, Enabled: true},
+	}
+	first := map[string]int{}
+	const runs = 4000
+	for i := 0; i < runs; i++ {
+		first[r.Order(cands)[0]]++
+	}
+	// expect roughly 75% / 25%
+	if first["heavy"] < 2800 || first["heavy"] > 3200 {
+		t.Fatalf("unexpected split: %v", first)
+	}
+}
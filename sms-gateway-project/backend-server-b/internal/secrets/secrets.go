@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// Encrypt seals plaintext with AES-256-GCM using the hex encoded key. The
+// output is base64(nonce | tag | ciphertext), matching the admin service.
+func Encrypt(plaintext, keyHex string) (string, error) {
+	gcm, err := newGCM(keyHex)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	ct, tag := sealed[:len(sealed)-tagSize], sealed[len(sealed)-tagSize:]
+
+	out := make([]byte, 0, nonceSize+tagSize+len(ct))
+	out = append(out, nonce...)
+	out = append(out, tag...)
+	out = append(out, ct...)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt opens a value produced by Encrypt or by the admin service.
+func Decrypt(ciphertext, keyHex string) (string, error) {
+	gcm, err := newGCM(keyHex)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < nonceSize+tagSize {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, tag, ct := data[:nonceSize], data[nonceSize:nonceSize+tagSize], data[nonceSize+tagSize:]
+
+	sealed := make([]byte, 0, len(ct)+tagSize)
+	sealed = append(sealed, ct...)
+	sealed = append(sealed, tag...)
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(keyHex string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
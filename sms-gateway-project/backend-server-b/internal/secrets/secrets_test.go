@@ -0,0 +1,41 @@
+package secrets
+
+import "testing"
+
+const testKey = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	ct, err := Encrypt("pass", testKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	plain, err := Decrypt(ct, testKey)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plain != "pass" {
+		t.Fatalf("expected pass, got %q", plain)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	ct, err := Encrypt("pass", testKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	other := "1111111111111111111111111111111111111111111111111111111111111111"
+	if _, err := Decrypt(ct, other); err == nil {
+		t.Fatal("expected error for wrong key")
+	}
+}
+
+func TestDecryptAdminServiceCiphertext(t *testing.T) {
+	// produced by encrypt('pass') in the Node admin service with the zero key
+	plain, err := Decrypt("db2HDU3e/oZNdVU8LczfhVd+6jy/52Uk4dYyjANPj6s=", testKey)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plain != "pass" {
+		t.Fatalf("expected pass, got %q", plain)
+	}
+}
@@ -1,64 +1,453 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
+	"time"
 
+	"sms-gateway/backend-server-b/internal/config"
 	"sms-gateway/backend-server-b/internal/models"
 	"sms-gateway/backend-server-b/internal/providers"
 	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/backend-server-b/internal/routing"
+	"sms-gateway/shared/contract"
+	"sms-gateway/shared/phone"
 )
 
-// MessagePayload represents the payload consumed from RabbitMQ.
-type MessagePayload struct {
-	TrackingID string   `json:"tracking_id"`
-	Recipient  string   `json:"recipient"`
-	Text       string   `json:"text"`
-	Providers  []string `json:"providers"`
-}
+// MessagePayload represents the payload consumed from RabbitMQ. It is the
+// contract shared with server A.
+type MessagePayload = contract.MessagePayload
 
 // PolicyEngine orchestrates provider selection and sending logic.
 type PolicyEngine struct {
 	Repo      *repository.MessageRepository
 	Providers map[string]providers.SmsProvider
+	Configs   map[string]config.ProviderConfig
+	Router    *routing.Router
+	// Batches, when set, records bulk batches and lets their messages be
+	// cancelled.
+	Batches *repository.BatchRepository
+	// Blocklist, when set, stops messages to blocked recipients.
+	Blocklist *repository.BlocklistRepository
+	// Country is the default country recipients are normalized for; empty
+	// means phone.DefaultCountry.
+	Country string
+
+	mu     sync.RWMutex
+	limits map[string]chan struct{}
+	sleep  func(context.Context, time.Duration) error
+	now    func() time.Time
 }
 
 // NewPolicyEngine creates a new PolicyEngine instance.
-func NewPolicyEngine(repo *repository.MessageRepository, provs map[string]providers.SmsProvider) *PolicyEngine {
-	return &PolicyEngine{Repo: repo, Providers: provs}
+func NewPolicyEngine(repo *repository.MessageRepository, provs map[string]providers.SmsProvider, cfgs map[string]config.ProviderConfig) *PolicyEngine {
+	return &PolicyEngine{
+		Repo:      repo,
+		Providers: provs,
+		Configs:   cfgs,
+		Router:    routing.NewRouter(rand.NewSource(time.Now().UnixNano())),
+		limits:    newLimits(cfgs),
+		sleep:     sleepContext,
+		now:       time.Now,
+	}
 }
 
-// ProcessMessage processes an incoming message payload.
-func (p *PolicyEngine) ProcessMessage(payload MessagePayload) error {
-	if err := p.Repo.UpdateMessageStatus(payload.TrackingID, "PROCESSING", ""); err != nil {
-		return err
+// SetProviders replaces the set of providers and their settings used for new
+// messages. Providers whose concurrency cap did not change keep their
+// semaphore, so sends still in flight count against the same cap.
+func (p *PolicyEngine) SetProviders(provs map[string]providers.SmsProvider, cfgs map[string]config.ProviderConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Providers = provs
+	p.Configs = cfgs
+	p.limits = updateLimits(p.limits, cfgs)
+}
+
+// newLimits builds a semaphore for every provider with a concurrency cap.
+func newLimits(cfgs map[string]config.ProviderConfig) map[string]chan struct{} {
+	return updateLimits(nil, cfgs)
+}
+
+// updateLimits returns the semaphores for cfgs, reusing those in old whose
+// capacity still matches the provider's cap.
+func updateLimits(old map[string]chan struct{}, cfgs map[string]config.ProviderConfig) map[string]chan struct{} {
+	limits := map[string]chan struct{}{}
+	for name, cfg := range cfgs {
+		if cfg.MaxConcurrency <= 0 {
+			continue
+		}
+		if sem, ok := old[name]; ok && cap(sem) == cfg.MaxConcurrency {
+			limits[name] = sem
+			continue
+		}
+		limits[name] = make(chan struct{}, cfg.MaxConcurrency)
 	}
+	return limits
+}
 
-	provs := payload.Providers
-	if len(provs) == 0 {
-		for name := range p.Providers {
-			provs = []string{name}
-			break
+// acquire waits for a free send slot on the named provider and returns the
+// function that frees it again.
+func (p *PolicyEngine) acquire(ctx context.Context, name string) (func(), error) {
+	p.mu.RLock()
+	sem := p.limits[name]
+	p.mu.RUnlock()
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// snapshot returns the current provider set and settings.
+func (p *PolicyEngine) snapshot() (map[string]providers.SmsProvider, map[string]config.ProviderConfig) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Providers, p.Configs
+}
+
+// ProviderType returns the configured type of the named provider, or the
+// name itself when the provider has no explicit type.
+func (p *PolicyEngine) ProviderType(name string) string {
+	_, cfgs := p.snapshot()
+	if kind := cfgs[name].Type; kind != "" {
+		return kind
+	}
+	return name
+}
+
+// route returns the names of the providers to try for payload, in order.
+// Providers requested by the client are tried in the given order; otherwise
+// the Router orders all known providers by priority and weight. Disabled
+// providers are never returned.
+func (p *PolicyEngine) route(payload MessagePayload) []string {
+	provs, cfgs := p.snapshot()
+
+	if len(payload.Providers) > 0 {
+		names := make([]string, 0, len(payload.Providers))
+		for _, name := range payload.Providers {
+			if provs[name] != nil && cfgs[name].Enabled() {
+				names = append(names, name)
+			}
 		}
+		return names
+	}
+
+	cands := make([]routing.Candidate, 0, len(provs))
+	for name := range provs {
+		cfg := cfgs[name]
+		cands = append(cands, routing.Candidate{Name: name, Priority: cfg.Priority, Weight: cfg.Weight, Enabled: cfg.Enabled()})
+	}
+	return p.Router.Order(cands)
+}
+
+// ErrAllProvidersFailed is returned when no provider accepted a message.
+var ErrAllProvidersFailed = errors.New("all providers failed")
+
+// terminalStatuses are the statuses a redelivered message is not sent from.
+var terminalStatuses = map[string]bool{
+	"SENT":        true,
+	"DELIVERED":   true,
+	"UNDELIVERED": true,
+	"EXPIRED":     true,
+	"REJECTED":    true,
+	"CANCELLED":   true,
+	"BLOCKED":     true,
+}
+
+const (
+	defaultSendTimeout  = 10 * time.Second
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// ProcessMessage processes an incoming message payload. Each provider is
+// retried with exponential backoff on retryable errors, up to its configured
+// number of retries; other errors fail over to the next provider at once.
+// A permanent rejection of the message stops processing: the message is
+// marked FAILED and nil is returned since redelivering it cannot help.
+// Likewise a message whose TTL has passed before an attempt is marked EXPIRED
+// instead of being sent late, and a message whose batch was cancelled is
+// marked CANCELLED. A message with a future send time is stored as SCHEDULED
+// for the Scheduler to release, and one whose recipient is on the blocklist
+// when it is due is marked BLOCKED. The message row is created by Ingest before
+// anything else happens, so its status is queryable from the first delivery,
+// and a redelivered message whose row already has a terminal status is
+// acknowledged without being sent again.
+//
+// Every attempt runs under the provider's configured timeout. When ctx is
+// cancelled the in-flight attempt is abandoned, the message goes back to
+// QUEUED and ctx's error is returned so the caller can redeliver it.
+func (p *PolicyEngine) ProcessMessage(ctx context.Context, payload MessagePayload) error {
+	payload = p.normalize(payload)
+	if err := p.Ingest(payload); err != nil {
+		return err
+	}
+	if done, err := p.finished(payload.TrackingID); err != nil || done {
+		return err
+	}
+	if p.batchCancelled(payload) {
+		p.cancel(payload.TrackingID)
+		return nil
+	}
+	if payload.Scheduled(p.now()) {
+		return p.schedule(payload)
+	}
+	if blocked, err := p.block(payload); err != nil || blocked {
+		return err
+	}
+	if payload.Expired(p.now()) {
+		p.expire(payload.TrackingID)
+		return nil
+	}
+	if err := p.Repo.UpdateMessageStatus(payload.TrackingID, "PROCESSING", ""); err != nil {
+		return err
 	}
 
+	available, cfgs := p.snapshot()
+	provs := p.route(payload)
+
+	msg := models.Message{
+		TrackingID: payload.TrackingID,
+		Recipient:  payload.Recipient,
+		Text:       payload.Text,
+	}
+	var lastErr error
 	for _, name := range provs {
-		prov := p.Providers[name]
+		prov := available[name]
 		if prov == nil {
 			continue
 		}
-		msg := models.Message{
-			TrackingID: payload.TrackingID,
-			Recipient:  payload.Recipient,
-			Text:       payload.Text,
-		}
-		if ref, err := prov.Send(msg); err == nil {
-			_ = p.Repo.UpdateMessageStatus(payload.TrackingID, "SENT", ref)
-			_ = p.Repo.CreateMessageEvent(payload.TrackingID, fmt.Sprintf("sent via %s", name))
-			return nil
+		cfg := cfgs[name]
+		for attempt := 1; ; attempt++ {
+			if p.batchCancelled(payload) {
+				p.cancel(payload.TrackingID)
+				return nil
+			}
+			if payload.Expired(p.now()) {
+				p.expire(payload.TrackingID)
+				return nil
+			}
+			ref, err := p.send(ctx, name, prov, cfg, msg)
+			// a send that succeeded counts even if ctx was cancelled meanwhile;
+			// requeueing it would send the message twice
+			if err == nil {
+				_ = p.Repo.MarkMessageSent(payload.TrackingID, ref)
+				_ = p.Repo.CreateMessageEvent(payload.TrackingID, fmt.Sprintf("sent via %s (attempt %d)", name, attempt))
+				return nil
+			}
+			if ctx.Err() != nil {
+				return p.interrupted(ctx, payload.TrackingID)
+			}
+			lastErr = err
+			_ = p.Repo.CreateMessageEvent(payload.TrackingID, fmt.Sprintf("attempt %d via %s failed: %v", attempt, name, err))
+			if providers.IsPermanent(err) {
+				p.recordFailure(payload.TrackingID, err)
+				_ = p.Repo.CreateMessageEvent(payload.TrackingID, fmt.Sprintf("rejected by %s", name))
+				return nil
+			}
+			if !providers.IsRetryable(err) || attempt > cfg.Retries {
+				break
+			}
+			if err := p.sleep(ctx, retryDelay(cfg.RetryBackoffMs, attempt)); err != nil {
+				return p.interrupted(ctx, payload.TrackingID)
+			}
 		}
 	}
 
-	_ = p.Repo.UpdateMessageStatus(payload.TrackingID, "FAILED", "")
+	p.recordFailure(payload.TrackingID, lastErr)
 	_ = p.Repo.CreateMessageEvent(payload.TrackingID, "all providers failed")
-	return fmt.Errorf("all providers failed")
+	return ErrAllProvidersFailed
+}
+
+// send makes a single attempt through prov, bounded by the provider's timeout.
+// It first waits for a free slot when the provider has a concurrency cap.
+func (p *PolicyEngine) send(ctx context.Context, name string, prov providers.SmsProvider, cfg config.ProviderConfig, msg models.Message) (string, error) {
+	release, err := p.acquire(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	timeout := defaultSendTimeout
+	if cfg.TimeoutMs > 0 {
+		timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return prov.Send(sendCtx, msg)
+}
+
+// Ingest records a consumed message as QUEUED together with its initial
+// event, creating its batch first if it belongs to one. It is a no-op for
+// messages that already have a row.
+func (p *PolicyEngine) Ingest(payload MessagePayload) error {
+	payload = p.normalize(payload)
+	if payload.BatchID != "" && p.Batches != nil {
+		if err := p.Batches.EnsureBatch(payload.BatchID, payload.BatchSize); err != nil {
+			return err
+		}
+	}
+	created, err := p.Repo.EnsureMessage(payload.TrackingID, payload.Recipient, payload.Text, payload.BatchID)
+	if err != nil || !created {
+		return err
+	}
+	return p.Repo.CreateMessageEvent(payload.TrackingID, "queued")
+}
+
+// finished reports whether the message already reached a terminal status, in
+// which case a redelivery of it is acknowledged without sending it again.
+// QUEUED, PROCESSING and FAILED messages are still processed so interrupted
+// and retried deliveries go through.
+func (p *PolicyEngine) finished(trackingID string) (bool, error) {
+	msg, err := p.Repo.GetMessageByTrackingID(trackingID)
+	if err != nil {
+		return false, err
+	}
+	return terminalStatuses[msg.Status], nil
+}
+
+// ExpireInQueue records that a message outlived its TTL while queued. A
+// message that is SCHEDULED or already reached a terminal status keeps its
+// status, since its queue copy expiring says nothing about its outcome.
+func (p *PolicyEngine) ExpireInQueue(payload MessagePayload) error {
+	if err := p.Ingest(payload); err != nil {
+		return err
+	}
+	msg, err := p.Repo.GetMessageByTrackingID(payload.TrackingID)
+	if err != nil {
+		return err
+	}
+	if msg.Status == "SCHEDULED" || terminalStatuses[msg.Status] {
+		return nil
+	}
+	if err := p.Repo.UpdateMessageStatus(payload.TrackingID, "EXPIRED", ""); err != nil {
+		return err
+	}
+	return p.Repo.CreateMessageEvent(payload.TrackingID, "expired in queue")
+}
+
+// normalize rewrites the recipient in E.164 form. Server A already does so;
+// this covers older publishers. Numbers that cannot be parsed are kept as
+// they are and left for the provider to reject.
+func (p *PolicyEngine) normalize(payload MessagePayload) MessagePayload {
+	country := p.Country
+	if country == "" {
+		country = phone.DefaultCountry
+	}
+	if recipient, err := phone.Normalize(payload.Recipient, country); err == nil {
+		payload.Recipient = recipient
+	}
+	return payload
+}
+
+// schedule stores a message to be released at its send time.
+func (p *PolicyEngine) schedule(payload MessagePayload) error {
+	body, err := contract.Encode(payload)
+	if err != nil {
+		return err
+	}
+	scheduled, err := p.Repo.ScheduleMessage(payload.TrackingID, payload.SendAt.UTC(), string(body))
+	if err != nil || !scheduled {
+		return err
+	}
+	return p.Repo.CreateMessageEvent(payload.TrackingID, "scheduled for "+payload.SendAt.UTC().Format(time.RFC3339))
+}
+
+// batchCancelled reports whether the message's batch has been cancelled.
+func (p *PolicyEngine) batchCancelled(payload MessagePayload) bool {
+	if payload.BatchID == "" || p.Batches == nil {
+		return false
+	}
+	cancelled, err := p.Batches.IsCancelled(payload.BatchID)
+	return err == nil && cancelled
+}
+
+// cancel marks a message of a cancelled batch, unless cancelling the batch
+// already did.
+func (p *PolicyEngine) cancel(trackingID string) {
+	if msg, err := p.Repo.GetMessageByTrackingID(trackingID); err == nil && msg.Status == "CANCELLED" {
+		return
+	}
+	_ = p.Repo.UpdateMessageStatus(trackingID, "CANCELLED", "")
+	_ = p.Repo.CreateMessageEvent(trackingID, "cancelled with batch")
+}
+
+// block marks the message BLOCKED when its recipient is on the global
+// blocklist or on its client's, and reports whether it did.
+func (p *PolicyEngine) block(payload MessagePayload) (bool, error) {
+	if p.Blocklist == nil {
+		return false, nil
+	}
+	entry, found, err := p.Blocklist.FindBlock(payload.Recipient, payload.Client)
+	if err != nil || !found {
+		return false, err
+	}
+	scope := "global blocklist"
+	if entry.Client != "" {
+		scope = "blocklist of client " + entry.Client
+	}
+	_ = p.Repo.UpdateMessageStatus(payload.TrackingID, "BLOCKED", "")
+	_ = p.Repo.CreateMessageEvent(payload.TrackingID, "blocked: recipient is on the "+scope)
+	return true, nil
+}
+
+// expire marks a message whose TTL passed before it could be sent.
+func (p *PolicyEngine) expire(trackingID string) {
+	_ = p.Repo.UpdateMessageStatus(trackingID, "EXPIRED", "")
+	_ = p.Repo.CreateMessageEvent(trackingID, "expired before sending")
+}
+
+// interrupted puts a message whose processing was cancelled back in the queue.
+func (p *PolicyEngine) interrupted(ctx context.Context, trackingID string) error {
+	_ = p.Repo.UpdateMessageStatus(trackingID, "QUEUED", "")
+	_ = p.Repo.CreateMessageEvent(trackingID, "processing interrupted")
+	return ctx.Err()
+}
+
+// recordFailure marks the message FAILED with the cause taken from err.
+func (p *PolicyEngine) recordFailure(trackingID string, err error) {
+	code, reason := "", "no provider available"
+	var serr *providers.SendError
+	if errors.As(err, &serr) {
+		code = serr.Code
+	}
+	if err != nil {
+		reason = err.Error()
+	}
+	_ = p.Repo.UpdateMessageFailure(trackingID, "FAILED", code, reason)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryDelay returns the wait before retry number attempt. The delay doubles
+// with every attempt starting at backoffMs and is jittered between half and
+// the full value so that retries from many workers do not line up.
+func retryDelay(backoffMs, attempt int) time.Duration {
+	base := defaultRetryBackoff
+	if backoffMs > 0 {
+		base = time.Duration(backoffMs) * time.Millisecond
+	}
+	delay := base << (attempt - 1)
+	if delay > maxRetryBackoff || delay <= 0 {
+		delay = maxRetryBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
 }
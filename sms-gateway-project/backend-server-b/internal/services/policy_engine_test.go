@@ -0,0 +1,640 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/config"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/providers"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/backend-server-b/internal/routing"
+)
+
+// fakeProvider fails with the queued errors before succeeding.
+type fakeProvider struct {
+	name  string
+	errs  []error
+	calls int
+}
+
+func (f *fakeProvider) GetName() string { return f.name }
+
+func (f *fakeProvider) Send(ctx context.Context, message models.Message) (string, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return "", f.errs[f.calls-1]
+	}
+	return "ref-" + f.name, nil
+}
+
+func newTestMessageRepo(t *testing.T) *repository.MessageRepository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Message{}, &models.MessageEvent{}, &models.Batch{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return repository.NewMessageRepository(db)
+}
+
+func newTestEngine(t *testing.T, repo *repository.MessageRepository, provs map[string]providers.SmsProvider, cfgs map[string]config.ProviderConfig) (*PolicyEngine, *[]time.Duration) {
+	t.Helper()
+	engine := NewPolicyEngine(repo, provs, cfgs)
+	engine.Router = routing.NewRouter(rand.NewSource(1))
+	var slept []time.Duration
+	engine.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return engine, &slept
+}
+
+func TestPolicyEngineRoute(t *testing.T) {
+	off := false
+	provs := map[string]providers.SmsProvider{
+		"Provider-A": providers.NewProviderA(config.ProviderConfig{}),
+		"Provider-B": providers.NewProviderB(config.ProviderConfig{}),
+		"magfa":      providers.NewMagfaProvider("magfa", config.ProviderConfig{}),
+	}
+	cfgs := map[string]config.ProviderConfig{
+		"Provider-A": {Priority: 20},
+		"Provider-B": {Priority: 10},
+		"magfa":      {Priority: 1, IsEnabled: &off},
+	}
+	engine, _ := newTestEngine(t, nil, provs, cfgs)
+
+	got := engine.route(MessagePayload{})
+	if want := []string{"Provider-B", "Provider-A"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got = engine.route(MessagePayload{Providers: []string{"magfa", "unknown", "Provider-A", "Provider-B"}})
+	if want := []string{"Provider-A", "Provider-B"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestProcessMessageRetriesRetryableErrors(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	retryable := &providers.SendError{Provider: "primary", HTTPStatus: 503, Retryable: true}
+	primary := &fakeProvider{name: "primary", errs: []error{retryable, retryable}}
+	engine, slept := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {Retries: 2, RetryBackoffMs: 100}})
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", primary.calls)
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("expected 2 backoffs, got %v", *slept)
+	}
+	d1, d2 := (*slept)[0], (*slept)[1]
+	if d1 < 50*time.Millisecond || d1 > 100*time.Millisecond || d2 < 100*time.Millisecond || d2 > 200*time.Millisecond {
+		t.Fatalf("unexpected backoff delays: %v", *slept)
+	}
+
+	msg, err := repo.GetMessageByTrackingID("t1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if msg.Status != "SENT" || msg.ProviderRef != "ref-primary" {
+		t.Fatalf("unexpected message state: %s %s", msg.Status, msg.ProviderRef)
+	}
+	if len(msg.Events) != 3 {
+		t.Fatalf("expected an event per attempt, got %d", len(msg.Events))
+	}
+}
+
+func TestProcessMessageFailsOverOnNonRetryableError(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	primary := &fakeProvider{name: "primary", errs: []error{&providers.SendError{Provider: "primary", HTTPStatus: 401}}}
+	backup := &fakeProvider{name: "backup"}
+	engine, slept := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary, "backup": backup},
+		map[string]config.ProviderConfig{"primary": {Priority: 1, Retries: 3}, "backup": {Priority: 2}})
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 1 || backup.calls != 1 || len(*slept) != 0 {
+		t.Fatalf("expected immediate failover, got primary=%d backup=%d sleeps=%v", primary.calls, backup.calls, *slept)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.ProviderRef != "ref-backup" {
+		t.Fatalf("expected backup ref, got %s", msg.ProviderRef)
+	}
+}
+
+func TestProcessMessageAllProvidersFail(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	retryable := &providers.SendError{Provider: "primary", HTTPStatus: 500, Retryable: true}
+	primary := &fakeProvider{name: "primary", errs: []error{retryable, retryable}}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {Retries: 1}})
+
+	err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"})
+	if !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("expected ErrAllProvidersFailed, got %v", err)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "FAILED" || msg.FailureReason != "primary: http status 500" {
+		t.Fatalf("unexpected failure state: %s %q", msg.Status, msg.FailureReason)
+	}
+	if len(msg.Events) != 3 || !strings.Contains(msg.Events[1].Event, "attempt 2 via primary failed") {
+		t.Fatalf("unexpected events: %+v", msg.Events)
+	}
+}
+
+func TestProcessMessageStopsOnPermanentRejection(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "bad", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	rejected := &providers.SendError{Provider: "primary", Code: "1", Message: "invalid recipient number", Permanent: true}
+	primary := &fakeProvider{name: "primary", errs: []error{rejected}}
+	backup := &fakeProvider{name: "backup"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary, "backup": backup},
+		map[string]config.ProviderConfig{"primary": {Priority: 1, Retries: 3}, "backup": {Priority: 2}})
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "bad", Text: "hi"}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 1 || backup.calls != 0 {
+		t.Fatalf("expected no retry or failover, got primary=%d backup=%d", primary.calls, backup.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "FAILED" || msg.FailureCode != "1" {
+		t.Fatalf("unexpected failure state: %s %q", msg.Status, msg.FailureCode)
+	}
+}
+
+// blockingProvider waits until the send context is done.
+type blockingProvider struct{ calls int }
+
+func (b *blockingProvider) GetName() string { return "blocking" }
+
+func (b *blockingProvider) Send(ctx context.Context, message models.Message) (string, error) {
+	b.calls++
+	<-ctx.Done()
+	return "", &providers.SendError{Provider: "blocking", Retryable: true, Err: ctx.Err()}
+}
+
+func TestProcessMessageAppliesProviderTimeout(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	hung := &blockingProvider{}
+	backup := &fakeProvider{name: "backup"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"hung": hung, "backup": backup},
+		map[string]config.ProviderConfig{"hung": {Priority: 1, TimeoutMs: 20}, "backup": {Priority: 2}})
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if hung.calls != 1 || backup.calls != 1 {
+		t.Fatalf("expected timeout then failover, got hung=%d backup=%d", hung.calls, backup.calls)
+	}
+}
+
+func TestProcessMessageCancelled(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	hung := &blockingProvider{}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"hung": hung},
+		map[string]config.ProviderConfig{"hung": {Retries: 5}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := engine.ProcessMessage(ctx, MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if hung.calls != 1 {
+		t.Fatalf("expected no retries after cancellation, got %d calls", hung.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "QUEUED" {
+		t.Fatalf("expected message back in QUEUED, got %s", msg.Status)
+	}
+}
+
+// cancellingProvider cancels the caller's context and then succeeds, like a
+// send that completes just as a shutdown times out.
+type cancellingProvider struct {
+	cancel context.CancelFunc
+	calls  int
+}
+
+func (c *cancellingProvider) GetName() string { return "cancelling" }
+
+func (c *cancellingProvider) Send(ctx context.Context, message models.Message) (string, error) {
+	c.calls++
+	c.cancel()
+	return "ref-1", nil
+}
+
+func TestProcessMessageKeepsSendCompletedDuringCancellation(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prov := &cancellingProvider{cancel: cancel}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"cancelling": prov},
+		map[string]config.ProviderConfig{"cancelling": {}})
+
+	if err := engine.ProcessMessage(ctx, MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}); err != nil {
+		t.Fatalf("a completed send must not be redelivered, got %v", err)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if prov.calls != 1 || msg.Status != "SENT" || msg.ProviderRef != "ref-1" {
+		t.Fatalf("unexpected state: calls=%d %s %q", prov.calls, msg.Status, msg.ProviderRef)
+	}
+}
+
+func TestProcessMessageExpired(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	expiresAt := time.Now().Add(-time.Second)
+	err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", TTL: 30, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 0 {
+		t.Fatalf("expired message must not be sent, got %d calls", primary.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "EXPIRED" || len(msg.Events) != 1 {
+		t.Fatalf("unexpected state: %s %+v", msg.Status, msg.Events)
+	}
+}
+
+func TestProcessMessageExpiresBetweenRetries(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.CreateInitialMessage("t1", "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	retryable := &providers.SendError{Provider: "primary", HTTPStatus: 503, Retryable: true}
+	primary := &fakeProvider{name: "primary", errs: []error{retryable, retryable}}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {Retries: 2}})
+
+	start := time.Now()
+	expiresAt := start.Add(time.Minute)
+	clock := start
+	engine.now = func() time.Time { return clock }
+	engine.sleep = func(ctx context.Context, d time.Duration) error {
+		clock = clock.Add(2 * time.Minute)
+		return nil
+	}
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("expected a single attempt before expiry, got %d", primary.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "EXPIRED" {
+		t.Fatalf("expected EXPIRED, got %s", msg.Status)
+	}
+}
+
+func TestProcessMessageCreatesRow(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	payload := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}
+	if err := engine.ProcessMessage(context.Background(), payload); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	msg, err := repo.GetMessageByTrackingID("t1")
+	if err != nil {
+		t.Fatalf("message not persisted: %v", err)
+	}
+	if msg.Status != "SENT" || msg.Recipient != "0912" || msg.Text != "hi" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if len(msg.Events) != 2 || msg.Events[0].Event != "queued" {
+		t.Fatalf("unexpected events: %+v", msg.Events)
+	}
+}
+
+func TestIngestIsIdempotent(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	engine, _ := newTestEngine(t, repo, nil, nil)
+
+	payload := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}
+	for i := 0; i < 2; i++ {
+		if err := engine.Ingest(payload); err != nil {
+			t.Fatalf("ingest %d: %v", i, err)
+		}
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "QUEUED" || len(msg.Events) != 1 {
+		t.Fatalf("unexpected state: %s %+v", msg.Status, msg.Events)
+	}
+}
+
+func TestExpireInQueueKeepsScheduledAndFinalMessages(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	engine, _ := newTestEngine(t, repo, nil, nil)
+
+	for id, status := range map[string]string{"queued": "QUEUED", "scheduled": "SCHEDULED", "sent": "SENT"} {
+		payload := MessagePayload{TrackingID: id, Recipient: "0912", Text: "hi"}
+		if err := engine.Ingest(payload); err != nil {
+			t.Fatalf("ingest %s: %v", id, err)
+		}
+		if err := repo.UpdateMessageStatus(id, status, ""); err != nil {
+			t.Fatalf("update %s: %v", id, err)
+		}
+		if err := engine.ExpireInQueue(payload); err != nil {
+			t.Fatalf("expire %s: %v", id, err)
+		}
+	}
+	want := map[string]string{"queued": "EXPIRED", "scheduled": "SCHEDULED", "sent": "SENT"}
+	for id, status := range want {
+		if msg, _ := repo.GetMessageByTrackingID(id); msg.Status != status {
+			t.Errorf("%s: expected %s, got %s", id, status, msg.Status)
+		}
+	}
+}
+
+func TestAcquireHonorsMaxConcurrency(t *testing.T) {
+	engine := NewPolicyEngine(nil, nil, map[string]config.ProviderConfig{"capped": {MaxConcurrency: 1}, "open": {}})
+
+	release, err := engine.acquire(context.Background(), "capped")
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := engine.acquire(ctx, "capped"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second send to wait for a slot, got %v", err)
+	}
+	release()
+	if release, err = engine.acquire(context.Background(), "capped"); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	release()
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.acquire(ctx, "open"); err != nil {
+			t.Fatalf("uncapped provider must not block: %v", err)
+		}
+	}
+}
+
+func TestSetProvidersUpdatesLimits(t *testing.T) {
+	engine := NewPolicyEngine(nil, nil, nil)
+	engine.SetProviders(nil, map[string]config.ProviderConfig{"capped": {MaxConcurrency: 2}})
+	if got := cap(engine.limits["capped"]); got != 2 {
+		t.Fatalf("expected limit 2, got %d", got)
+	}
+}
+
+func TestSetProvidersKeepsUnchangedLimits(t *testing.T) {
+	engine := NewPolicyEngine(nil, nil, nil)
+	engine.SetProviders(nil, map[string]config.ProviderConfig{"same": {MaxConcurrency: 2}, "changed": {MaxConcurrency: 1}})
+	same, changed := engine.limits["same"], engine.limits["changed"]
+	same <- struct{}{} // a send in flight
+
+	engine.SetProviders(nil, map[string]config.ProviderConfig{"same": {MaxConcurrency: 2}, "changed": {MaxConcurrency: 3}})
+	if engine.limits["same"] != same || len(engine.limits["same"]) != 1 {
+		t.Fatal("expected the unchanged cap to keep its semaphore")
+	}
+	if engine.limits["changed"] == changed || cap(engine.limits["changed"]) != 3 {
+		t.Fatalf("expected a new semaphore of 3, got %d", cap(engine.limits["changed"]))
+	}
+}
+
+func TestProcessMessageCancelledBatch(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+	engine.Batches = repository.NewBatchRepository(repo.DB)
+
+	first := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", BatchID: "b1", BatchSize: 2}
+	if err := engine.ProcessMessage(context.Background(), first); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	batch, err := engine.Batches.GetBatch("b1")
+	if err != nil || batch.Size != 2 {
+		t.Fatalf("batch not created: %+v %v", batch, err)
+	}
+	if _, err := engine.Batches.CancelBatch("b1"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	second := MessagePayload{TrackingID: "t2", Recipient: "0913", Text: "hi", BatchID: "b1", BatchSize: 2}
+	if err := engine.ProcessMessage(context.Background(), second); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("cancelled message must not be sent, got %d calls", primary.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t2")
+	if msg.Status != "CANCELLED" || msg.BatchID != "b1" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	sent, _ := repo.GetMessageByTrackingID("t1")
+	if sent.Status != "SENT" {
+		t.Fatalf("sent message must keep its status, got %s", sent.Status)
+	}
+}
+
+func TestProcessMessageSchedulesFutureSend(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	sendAt := time.Now().Add(time.Hour).UTC()
+	payload := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", SendAt: &sendAt}
+	if err := engine.ProcessMessage(context.Background(), payload); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if primary.calls != 0 {
+		t.Fatalf("scheduled message must not be sent yet, got %d calls", primary.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Status != "SCHEDULED" || msg.SendAt == nil || !msg.SendAt.Equal(sendAt) {
+		t.Fatalf("unexpected state: %s %v", msg.Status, msg.SendAt)
+	}
+	if !strings.Contains(msg.Payload, `"tracking_id":"t1"`) {
+		t.Fatalf("payload not stored: %q", msg.Payload)
+	}
+}
+
+func TestIngestRecordsEncodingAndSegments(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	engine, _ := newTestEngine(t, repo, nil, nil)
+
+	payloads := []MessagePayload{
+		{TrackingID: "latin", Recipient: "0912", Text: strings.Repeat("a", 161)},
+		{TrackingID: "persian", Recipient: "0912", Text: strings.Repeat("س", 70)},
+	}
+	for _, p := range payloads {
+		if err := engine.Ingest(p); err != nil {
+			t.Fatalf("ingest %s: %v", p.TrackingID, err)
+		}
+	}
+	latin, _ := repo.GetMessageByTrackingID("latin")
+	persian, _ := repo.GetMessageByTrackingID("persian")
+	if latin.Encoding != "GSM-7" || latin.Segments != 2 {
+		t.Errorf("latin: %s/%d", latin.Encoding, latin.Segments)
+	}
+	if persian.Encoding != "UCS-2" || persian.Segments != 1 {
+		t.Errorf("persian: %s/%d", persian.Encoding, persian.Segments)
+	}
+}
+
+func TestProcessMessageNormalizesRecipient(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &recordingProvider{}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	if err := engine.ProcessMessage(context.Background(), MessagePayload{TrackingID: "t1", Recipient: "0912 123 4567", Text: "hi"}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	msg, _ := repo.GetMessageByTrackingID("t1")
+	if msg.Recipient != "+989121234567" || primary.recipient != "+989121234567" {
+		t.Fatalf("recipient not normalized: stored %q, sent %q", msg.Recipient, primary.recipient)
+	}
+}
+
+// recordingProvider remembers the recipient it was asked to send to.
+type recordingProvider struct {
+	recipient string
+}
+
+func (r *recordingProvider) GetName() string { return "recording" }
+
+func (r *recordingProvider) Send(ctx context.Context, message models.Message) (string, error) {
+	r.recipient = message.Recipient
+	return "ref", nil
+}
+
+func TestProcessMessageBlocked(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	if err := repo.DB.AutoMigrate(&models.BlocklistEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	blocklist := repository.NewBlocklistRepository(repo.DB)
+	if _, err := blocklist.AddEntries([]models.BlocklistEntry{{Phone: "+989121234567", Client: "shop"}}); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+	engine.Blocklist = blocklist
+
+	for _, p := range []MessagePayload{
+		{TrackingID: "blocked", Recipient: "09121234567", Text: "hi", Client: "shop"},
+		{TrackingID: "other-client", Recipient: "09121234567", Text: "hi", Client: "bank"},
+	} {
+		if err := engine.ProcessMessage(context.Background(), p); err != nil {
+			t.Fatalf("process %s: %v", p.TrackingID, err)
+		}
+	}
+	if primary.calls != 1 {
+		t.Fatalf("expected only the other client's message to be sent, got %d calls", primary.calls)
+	}
+	msg, _ := repo.GetMessageByTrackingID("blocked")
+	if msg.Status != "BLOCKED" || len(msg.Events) != 2 || !strings.Contains(msg.Events[1].Event, "client shop") {
+		t.Fatalf("unexpected state: %s %+v", msg.Status, msg.Events)
+	}
+	if other, _ := repo.GetMessageByTrackingID("other-client"); other.Status != "SENT" {
+		t.Fatalf("expected SENT, got %s", other.Status)
+	}
+}
+
+func TestProcessMessageSkipsFinishedRedelivery(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &fakeProvider{name: "primary"}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	payload := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}
+	if err := engine.ProcessMessage(context.Background(), payload); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if err := repo.UpdateMessageStatus("t1", "DELIVERED", "ref-primary"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := engine.ProcessMessage(context.Background(), payload); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("redelivered message must not be sent again, got %d calls", primary.calls)
+	}
+	if msg, _ := repo.GetMessageByTrackingID("t1"); msg.Status != "DELIVERED" {
+		t.Fatalf("redelivery must keep the status, got %s", msg.Status)
+	}
+}
+
+func TestProcessMessageRetriesFailedRedelivery(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	primary := &fakeProvider{name: "primary", errs: []error{errors.New("down")}}
+	engine, _ := newTestEngine(t, repo,
+		map[string]providers.SmsProvider{"primary": primary},
+		map[string]config.ProviderConfig{"primary": {}})
+
+	payload := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi"}
+	if err := engine.ProcessMessage(context.Background(), payload); !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("expected ErrAllProvidersFailed, got %v", err)
+	}
+	if err := engine.ProcessMessage(context.Background(), payload); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if msg, _ := repo.GetMessageByTrackingID("t1"); primary.calls != 2 || msg.Status != "SENT" {
+		t.Fatalf("expected the retry to send, got %d calls and %s", primary.calls, msg.Status)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"sms-gateway/backend-server-b/internal/config"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/providers"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/backend-server-b/internal/secrets"
+)
+
+// ProviderRegistry keeps the PolicyEngine's providers in sync with the
+// sms_providers table.
+type ProviderRegistry struct {
+	Repo     *repository.ProviderRepository
+	Engine   *PolicyEngine
+	KMSKey   string
+	Interval time.Duration
+
+	signature string
+	// loaded is set once providers were taken from the table.
+	loaded bool
+}
+
+// NewProviderRegistry creates a new ProviderRegistry.
+func NewProviderRegistry(repo *repository.ProviderRepository, engine *PolicyEngine, kmsKey string, interval time.Duration) *ProviderRegistry {
+	return &ProviderRegistry{Repo: repo, Engine: engine, KMSKey: kmsKey, Interval: interval}
+}
+
+// Start loads providers immediately and then refreshes them on every interval
+// until ctx is cancelled.
+func (r *ProviderRegistry) Start(ctx context.Context) {
+	if err := r.Refresh(); err != nil {
+		log.Printf("provider registry: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Refresh(); err != nil {
+					log.Printf("provider registry: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Refresh reloads providers from the database and hands them to the engine
+// when the table changed since the last load. An empty table leaves the
+// environment configuration in place until providers were first loaded from
+// the table; after that, emptying the table removes every provider.
+func (r *ProviderRegistry) Refresh() error {
+	rows, err := r.Repo.GetAllProviders()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 && !r.loaded {
+		return nil
+	}
+	sig := signature(rows)
+	if r.loaded && sig == r.signature {
+		return nil
+	}
+
+	provs := map[string]providers.SmsProvider{}
+	cfgs := map[string]config.ProviderConfig{}
+	for _, row := range rows {
+		if !row.IsEnabled {
+			continue
+		}
+		cfg, err := r.providerConfig(row)
+		if err != nil {
+			log.Printf("provider registry: skipping %s: %v", row.Name, err)
+			continue
+		}
+		if p := providers.GetProvider(row.Name, cfg); p != nil {
+			provs[row.Name] = p
+			cfgs[row.Name] = cfg
+		}
+	}
+
+	r.Engine.SetProviders(provs, cfgs)
+	r.signature = sig
+	r.loaded = true
+	log.Printf("provider registry: loaded %d enabled providers", len(provs))
+	return nil
+}
+
+// providerConfig converts a database row into a ProviderConfig.
+func (r *ProviderRegistry) providerConfig(row models.Provider) (config.ProviderConfig, error) {
+	cfg := config.ProviderConfig{
+		Type:           row.Type,
+		APIURL:         row.BaseURL + row.EndpointPath,
+		TimeoutMs:      row.TimeoutMs,
+		Retries:        row.Retries,
+		RetryBackoffMs: row.RetryBackoffMs,
+		Priority:       row.Priority,
+		Weight:         row.Weight,
+		MaxConcurrency: row.MaxConcurrency,
+		IsEnabled:      &row.IsEnabled,
+	}
+	if row.DefaultSender != nil {
+		cfg.Sender = *row.DefaultSender
+	}
+	if row.ExtraHeadersJSON != nil && *row.ExtraHeadersJSON != "" {
+		if err := json.Unmarshal([]byte(*row.ExtraHeadersJSON), &cfg.ExtraHeaders); err != nil {
+			return cfg, fmt.Errorf("extra headers: %w", err)
+		}
+	}
+	if row.AuthType == "basic" {
+		if row.BasicUsername != nil {
+			cfg.Username = *row.BasicUsername
+		}
+		if row.BasicPasswordCiphertext != nil && *row.BasicPasswordCiphertext != "" {
+			pass, err := secrets.Decrypt(*row.BasicPasswordCiphertext, r.KMSKey)
+			if err != nil {
+				return cfg, fmt.Errorf("decrypt password: %w", err)
+			}
+			cfg.Password = pass
+		}
+	}
+	return cfg, nil
+}
+
+// signature identifies a particular state of the provider table.
+func signature(rows []models.Provider) string {
+	parts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		parts = append(parts, fmt.Sprintf("%s:%d:%t", row.ID, row.UpdatedAt.UnixNano(), row.IsEnabled))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"sms-gateway/backend-server-b/internal/config"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/providers"
+	"sms-gateway/backend-server-b/internal/repository"
+	"sms-gateway/backend-server-b/internal/secrets"
+)
+
+const testKMSKey = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestProviderRegistryRefresh(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Provider{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	ct, err := secrets.Encrypt("pass", testKMSKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	user := "user/domain"
+	rows := []models.Provider{
+		{ID: "1", Name: "magfa-prod", Type: "magfa", BaseURL: "https://sms.magfa.com", EndpointPath: "/api/http/sms/v2/send",
+			AuthType: "basic", BasicUsername: &user, BasicPasswordCiphertext: &ct, MaxConcurrency: 3, IsEnabled: true},
+		{ID: "2", Name: "magfa-backup", Type: "magfa", BaseURL: "https://sms.magfa.com", EndpointPath: "/api/http/sms/v2/send",
+			AuthType: "basic", IsEnabled: false},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	engine := NewPolicyEngine(nil, map[string]providers.SmsProvider{"Provider-A": providers.NewProviderA(config.ProviderConfig{})}, nil)
+	reg := NewProviderRegistry(repository.NewProviderRepository(db), engine, testKMSKey, time.Minute)
+
+	if err := reg.Refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	provs, cfgs := engine.snapshot()
+	if len(provs) != 1 || provs["magfa-prod"] == nil {
+		t.Fatalf("expected only magfa-prod, got %v", provs)
+	}
+	if cfgs["magfa-prod"].MaxConcurrency != 3 {
+		t.Fatalf("expected max concurrency 3, got %d", cfgs["magfa-prod"].MaxConcurrency)
+	}
+
+	// toggling providers in the admin UI bumps updated_at
+	db.Model(&models.Provider{}).Where("id = ?", "1").Updates(map[string]any{"is_enabled": false, "updated_at": time.Now().Add(time.Second)})
+	db.Model(&models.Provider{}).Where("id = ?", "2").Updates(map[string]any{"is_enabled": true, "updated_at": time.Now().Add(time.Second)})
+	if err := reg.Refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	provs, _ = engine.snapshot()
+	if len(provs) != 1 || provs["magfa-backup"] == nil {
+		t.Fatalf("expected only magfa-backup, got %v", provs)
+	}
+
+	// once the table was in use, deleting every row removes the providers
+	db.Where("1 = 1").Delete(&models.Provider{})
+	if err := reg.Refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if provs, _ = engine.snapshot(); len(provs) != 0 {
+		t.Fatalf("expected no providers, got %v", provs)
+	}
+}
+
+func TestProviderRegistryKeepsEnvProvidersWhenTableEmpty(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Provider{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	engine := NewPolicyEngine(nil, map[string]providers.SmsProvider{"Provider-A": providers.NewProviderA(config.ProviderConfig{})}, nil)
+	reg := NewProviderRegistry(repository.NewProviderRepository(db), engine, testKMSKey, time.Minute)
+	if err := reg.Refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if provs, _ := engine.snapshot(); provs["Provider-A"] == nil {
+		t.Fatal("expected environment providers to be kept")
+	}
+}
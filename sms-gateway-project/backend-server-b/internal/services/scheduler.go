@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+const (
+	// schedulerBatchSize caps the messages released per poll.
+	schedulerBatchSize = 100
+	// schedulerLease is how long a claimed message is reserved for the run
+	// releasing it before another run may take it over.
+	schedulerLease = 5 * time.Minute
+)
+
+// Releaser puts a message body back on the main queue.
+type Releaser interface {
+	Replay(ctx context.Context, body []byte) error
+}
+
+// Scheduler releases SCHEDULED messages once their send time has come by
+// publishing their stored payload to the main queue, where they are processed
+// like any other message. Scheduled messages live in the database, so they
+// survive restarts of the worker and the broker. A message is only marked
+// released after it was published; if the worker stops in between, a later
+// run publishes it again once its lease has expired.
+type Scheduler struct {
+	Repo     *repository.MessageRepository
+	Releaser Releaser
+	Interval time.Duration
+
+	now func() time.Time
+}
+
+// NewScheduler creates a new Scheduler.
+func NewScheduler(repo *repository.MessageRepository, releaser Releaser, interval time.Duration) *Scheduler {
+	return &Scheduler{Repo: repo, Releaser: releaser, Interval: interval, now: time.Now}
+}
+
+// Start releases due messages on every interval until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.ReleaseDue(ctx); err != nil {
+					log.Printf("scheduler: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ReleaseDue publishes every message whose send time has passed and returns
+// how many were released. Messages that cannot be published stay scheduled
+// and are tried again on the next run.
+func (s *Scheduler) ReleaseDue(ctx context.Context) (int, error) {
+	released := 0
+	for {
+		due, err := s.Repo.ClaimDueMessages(s.now().UTC(), schedulerLease, schedulerBatchSize)
+		if err != nil {
+			return released, err
+		}
+		for i, msg := range due {
+			if err := s.Releaser.Replay(ctx, []byte(msg.Payload)); err != nil {
+				// put back this message and the rest of the claimed ones
+				for _, m := range due[i:] {
+					_ = s.Repo.UnclaimMessage(m.TrackingID)
+				}
+				return released, err
+			}
+			// should this fail, the lease expires and the message is published
+			// again, which the consumer tolerates like any redelivery
+			_ = s.Repo.MarkReleased(msg.TrackingID)
+			_ = s.Repo.CreateMessageEvent(msg.TrackingID, "released by scheduler")
+			released++
+		}
+		if len(due) < schedulerBatchSize {
+			return released, nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sms-gateway/backend-server-b/internal/repository"
+)
+
+// fakeReleaser records released bodies and fails after the first limit ones.
+type fakeReleaser struct {
+	bodies [][]byte
+	limit  int
+}
+
+func (f *fakeReleaser) Replay(ctx context.Context, body []byte) error {
+	if f.limit >= 0 && len(f.bodies) >= f.limit {
+		return errors.New("broker unavailable")
+	}
+	f.bodies = append(f.bodies, body)
+	return nil
+}
+
+func scheduleTestMessage(t *testing.T, repo *repository.MessageRepository, trackingID string, sendAt time.Time) {
+	t.Helper()
+	if err := repo.CreateInitialMessage(trackingID, "0912", "hi"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ok, err := repo.ScheduleMessage(trackingID, sendAt, `{"tracking_id":"`+trackingID+`"}`); !ok || err != nil {
+		t.Fatalf("schedule %s: %v %v", trackingID, ok, err)
+	}
+}
+
+func TestSchedulerReleasesDueMessages(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduleTestMessage(t, repo, "due", now.Add(-time.Minute))
+	scheduleTestMessage(t, repo, "later", now.Add(time.Hour))
+
+	releaser := &fakeReleaser{limit: -1}
+	s := NewScheduler(repo, releaser, time.Second)
+	s.now = func() time.Time { return now }
+
+	n, err := s.ReleaseDue(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expected one release, got %d %v", n, err)
+	}
+	if len(releaser.bodies) != 1 || string(releaser.bodies[0]) != `{"tracking_id":"due"}` {
+		t.Fatalf("unexpected bodies: %q", releaser.bodies)
+	}
+	due, _ := repo.GetMessageByTrackingID("due")
+	later, _ := repo.GetMessageByTrackingID("later")
+	if due.Status != "QUEUED" || later.Status != "SCHEDULED" {
+		t.Fatalf("unexpected statuses: due=%s later=%s", due.Status, later.Status)
+	}
+
+	// a second run finds nothing new
+	if n, err := s.ReleaseDue(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no releases, got %d %v", n, err)
+	}
+}
+
+func TestSchedulerKeepsMessagesScheduledOnFailure(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduleTestMessage(t, repo, "t1", now.Add(-2*time.Minute))
+	scheduleTestMessage(t, repo, "t2", now.Add(-time.Minute))
+
+	releaser := &fakeReleaser{limit: 1}
+	s := NewScheduler(repo, releaser, time.Second)
+	s.now = func() time.Time { return now }
+
+	n, err := s.ReleaseDue(context.Background())
+	if err == nil || n != 1 {
+		t.Fatalf("expected one release and an error, got %d %v", n, err)
+	}
+	t1, _ := repo.GetMessageByTrackingID("t1")
+	t2, _ := repo.GetMessageByTrackingID("t2")
+	if t1.Status != "QUEUED" || t2.Status != "SCHEDULED" {
+		t.Fatalf("unexpected statuses: t1=%s t2=%s", t1.Status, t2.Status)
+	}
+}
+
+func TestSchedulerRetakesInterruptedRelease(t *testing.T) {
+	repo := newTestMessageRepo(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduleTestMessage(t, repo, "t1", now.Add(-time.Minute))
+
+	// a run claimed the message and stopped before publishing it
+	if claimed, err := repo.ClaimDueMessages(now, schedulerLease, schedulerBatchSize); err != nil || len(claimed) != 1 {
+		t.Fatalf("claim: %v %v", claimed, err)
+	}
+	if msg, _ := repo.GetMessageByTrackingID("t1"); msg.Status != "SCHEDULED" {
+		t.Fatalf("claimed message must stay scheduled, got %s", msg.Status)
+	}
+
+	releaser := &fakeReleaser{limit: -1}
+	s := NewScheduler(repo, releaser, time.Second)
+	s.now = func() time.Time { return now.Add(time.Minute) }
+	if n, err := s.ReleaseDue(context.Background()); err != nil || n != 0 {
+		t.Fatalf("the lease must hold, got %d %v", n, err)
+	}
+
+	s.now = func() time.Time { return now.Add(schedulerLease + time.Second) }
+	if n, err := s.ReleaseDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected the expired lease to be taken over, got %d %v", n, err)
+	}
+	if msg, _ := repo.GetMessageByTrackingID("t1"); msg.Status != "QUEUED" || msg.ReleaseLease != nil {
+		t.Fatalf("unexpected state: %s %v", msg.Status, msg.ReleaseLease)
+	}
+}
@@ -1,57 +1,525 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"sms-gateway/backend-server-b/internal/models"
+	"sms-gateway/backend-server-b/internal/repository"
 	"sms-gateway/backend-server-b/internal/services"
+	"sms-gateway/shared/contract"
 )
 
+// AttemptHeader carries the number of times a message has been delivered.
+const AttemptHeader = "x-delivery-attempt"
+
+// DefaultMaxAttempts is used when no maximum delivery count is configured.
+const DefaultMaxAttempts = 5
+
+// DefaultRetryDelays are the retry tiers used when none are configured.
+var DefaultRetryDelays = []time.Duration{10 * time.Second, time.Minute, 10 * time.Minute}
+
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// ConsumerState describes what the consumer is currently doing.
+type ConsumerState string
+
+const (
+	StateConnecting   ConsumerState = "connecting"
+	StateConsuming    ConsumerState = "consuming"
+	StateReconnecting ConsumerState = "reconnecting"
+	StateStopped      ConsumerState = "stopped"
+)
+
+// ConsumerStatus is a snapshot of the consumer's state for health checks.
+type ConsumerStatus struct {
+	State      ConsumerState `json:"state"`
+	Since      time.Time     `json:"since"`
+	LastError  string        `json:"last_error,omitempty"`
+	Reconnects int           `json:"reconnects"`
+}
+
+// Healthy reports whether the consumer is receiving messages.
+func (s ConsumerStatus) Healthy() bool {
+	return s.State == StateConsuming
+}
+
 // Consumer consumes messages from RabbitMQ and processes them via PolicyEngine.
+// Failed messages wait in tiered retry queues before being delivered again;
+// messages that keep failing are moved to the dead-letter queue, which the
+// consumer drains into the database for inspection.
+//
+// Concurrency workers process deliveries in parallel. The broker's prefetch
+// is set to the same number, so every worker has at most one unacknowledged
+// delivery and each delivery is settled individually by its tag.
+//
+// Retry copies and replayed messages are published on a confirm-mode channel,
+// and a failed delivery is only acknowledged once the broker confirmed its
+// retry copy.
+//
+// The consumer supervises its broker connection: when the connection or one
+// of its channels closes it reconnects with exponential backoff and declares
+// the topology again.
 type Consumer struct {
-	ConnURL   string
-	QueueName string
-	Engine    *services.PolicyEngine
+	ConnURL     string
+	QueueName   string
+	Engine      *services.PolicyEngine
+	DeadLetters *repository.DeadLetterRepository
+	MaxAttempts int
+	RetryDelays []time.Duration
+	Concurrency int
+
+	mu     sync.Mutex
+	pubCh  *amqp.Channel
+	status ConsumerStatus
+
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
 }
 
 // NewConsumer creates a new Consumer.
-func NewConsumer(url, queue string, engine *services.PolicyEngine) *Consumer {
-	return &Consumer{ConnURL: url, QueueName: queue, Engine: engine}
+func NewConsumer(url, queue string, engine *services.PolicyEngine, deadLetters *repository.DeadLetterRepository, maxAttempts int, retryDelays []time.Duration, concurrency int) *Consumer {
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if len(retryDelays) == 0 {
+		retryDelays = DefaultRetryDelays
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Consumer{
+		ConnURL:     url,
+		QueueName:   queue,
+		Engine:      engine,
+		DeadLetters: deadLetters,
+		MaxAttempts: maxAttempts,
+		RetryDelays: retryDelays,
+		Concurrency: concurrency,
+		status:      ConsumerStatus{State: StateStopped, Since: time.Now()},
+		stopping:    make(chan struct{}),
+	}
 }
 
-// StartConsumer starts consuming messages.
-func (c *Consumer) StartConsumer() error {
-	conn, err := amqp.Dial(c.ConnURL)
+// StartConsumer connects to the broker and consumes messages until ctx is
+// cancelled, reconnecting whenever the connection is lost. Only the first
+// connection attempt is reported as an error. Cancelling ctx also cancels the
+// send in flight; that message is requeued.
+func (c *Consumer) StartConsumer(ctx context.Context) error {
+	c.setState(StateConnecting, nil)
+	sess, err := c.connect()
 	if err != nil {
+		c.setState(StateStopped, err)
 		return err
 	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		c.supervise(ctx, sess)
+	}()
+	return nil
+}
+
+// Shutdown stops taking deliveries and waits for the messages in flight to
+// finish. If ctx ends first, the remaining sends are cancelled, their
+// messages are requeued, and ctx's error is returned once they are settled.
+// Prefetched deliveries that were never started go back to the queue when
+// the connection closes.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stopping) })
+	if c.done == nil {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+// Status returns the consumer's current state.
+func (c *Consumer) Status() ConsumerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// session holds one broker connection and its deliveries.
+type session struct {
+	conn   *amqp.Connection
+	closed <-chan *amqp.Error
+	msgs   <-chan amqp.Delivery
+	dead   <-chan amqp.Delivery
+}
+
+// connect opens a connection, declares the topology and starts consuming the
+// main queue and the dead-letter queue.
+func (c *Consumer) connect() (*session, error) {
+	conn, err := amqp.Dial(c.ConnURL)
+	if err != nil {
+		return nil, err
+	}
+	sess := &session{conn: conn, closed: conn.NotifyClose(make(chan *amqp.Error, 1))}
+	if err := DeclareTopology(conn, c.QueueName, c.RetryDelays); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	ch, err := conn.Channel()
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
-	if _, err := ch.QueueDeclare(c.QueueName, true, false, false, false, nil); err != nil {
-		return err
+	if err := ch.Qos(c.Concurrency, 0, false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	pubCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := pubCh.Confirm(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	dlqCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
 
-	msgs, err := ch.Consume(c.QueueName, "", false, false, false, false, nil)
+	sess.msgs, err = ch.Consume(c.QueueName, "", false, false, false, false, nil)
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, err
+	}
+	sess.dead, err = dlqCh.Consume(DeadLetterQueue(c.QueueName), "", false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
 
+	c.mu.Lock()
+	c.pubCh = pubCh
+	c.mu.Unlock()
+	return sess, nil
+}
+
+// supervise serves sess and replaces it whenever it is lost, until ctx is
+// cancelled.
+func (c *Consumer) supervise(ctx context.Context, sess *session) {
+	for {
+		err := c.serve(ctx, sess)
+		c.mu.Lock()
+		c.pubCh = nil
+		c.mu.Unlock()
+		if ctx.Err() != nil || c.isStopping() {
+			c.setState(StateStopped, nil)
+			return
+		}
+
+		log.Printf("consumer: %v; reconnecting", err)
+		c.setState(StateReconnecting, err)
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-ctx.Done():
+				c.setState(StateStopped, nil)
+				return
+			case <-c.stopping:
+				c.setState(StateStopped, nil)
+				return
+			case <-time.After(reconnectDelay(attempt)):
+			}
+			if sess, err = c.connect(); err == nil {
+				break
+			}
+			log.Printf("consumer: reconnect attempt %d: %v", attempt+1, err)
+			c.setState(StateReconnecting, err)
+		}
+		c.mu.Lock()
+		c.status.Reconnects++
+		c.mu.Unlock()
+	}
+}
+
+// serve runs the workers on sess until ctx is cancelled, Shutdown is called
+// or the session is lost, in which case it returns the reason. It closes the
+// connection and waits for the workers before returning.
+func (c *Consumer) serve(ctx context.Context, sess *session) error {
+	c.setState(StateConsuming, nil)
+	drained := make(chan struct{})
 	go func() {
-		for msg := range msgs {
-			var payload services.MessagePayload
-			if err := json.Unmarshal(msg.Body, &payload); err != nil {
-				msg.Nack(false, false)
+		defer close(drained)
+		c.drainDeadLetters(ctx, sess.dead)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.work(ctx, sess.msgs)
+		}()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		// let the workers settle their in-flight deliveries first
+		<-stopped
+		err = ctx.Err()
+	case <-c.stopping:
+		<-stopped
+		err = errors.New("consumer shutting down")
+	case cerr := <-sess.closed:
+		err = fmt.Errorf("connection closed: %v", cerr)
+	case <-drained:
+		err = errors.New("dead-letter channel closed")
+	case <-stopped:
+		err = errors.New("delivery channel closed")
+	}
+	sess.conn.Close()
+	<-stopped
+	<-drained
+	return err
+}
+
+// work handles deliveries until msgs is closed, ctx is cancelled or the
+// consumer is shutting down.
+func (c *Consumer) work(ctx context.Context, msgs <-chan amqp.Delivery) {
+	for !c.isStopping() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopping:
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			c.handle(ctx, msg)
+		}
+	}
+}
+
+// isStopping reports whether Shutdown has been called.
+func (c *Consumer) isStopping() bool {
+	select {
+	case <-c.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// setState records the consumer's state and the error that led to it, if any.
+func (c *Consumer) setState(state ConsumerState, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.State != state {
+		c.status.State = state
+		c.status.Since = time.Now()
+	}
+	c.status.LastError = ""
+	if err != nil {
+		c.status.LastError = err.Error()
+	}
+}
+
+// reconnectDelay returns the wait before the given (0-based) reconnect attempt.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectMinDelay
+	for i := 0; i < attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, reconnectMaxDelay)
+}
+
+// Replay publishes body to the main queue as a fresh delivery.
+func (c *Consumer) Replay(ctx context.Context, body []byte) error {
+	return c.publish(ctx, c.QueueName, body, nil)
+}
+
+// handle processes a single delivery and settles it.
+func (c *Consumer) handle(ctx context.Context, msg amqp.Delivery) {
+	payload, err := contract.Decode(msg.Body)
+	if err != nil {
+		log.Printf("consumer: dead-lettering invalid message %q: %v", payload.TrackingID, err)
+		msg.Nack(false, false)
+		return
+	}
+	err = c.Engine.ProcessMessage(ctx, payload)
+	switch {
+	case err == nil:
+		msg.Ack(false)
+	case ctx.Err() != nil:
+		// shutting down; another consumer picks the message up
+		msg.Nack(false, true)
+	default:
+		c.redeliver(ctx, msg, payload.TrackingID, err)
+	}
+}
+
+// redeliver parks a failed message in the retry queue for its attempt, or
+// dead-letters it once MaxAttempts deliveries have failed.
+func (c *Consumer) redeliver(ctx context.Context, msg amqp.Delivery, trackingID string, cause error) {
+	attempt := deliveryAttempt(msg.Headers)
+	if attempt >= c.MaxAttempts {
+		log.Printf("consumer: dead-lettering %s after %d attempts: %v", trackingID, attempt, cause)
+		_ = c.Engine.Repo.CreateMessageEvent(trackingID, fmt.Sprintf("dead-lettered after %d attempts", attempt))
+		msg.Nack(false, false)
+		return
+	}
+	tier, delay := retryTier(attempt, c.RetryDelays)
+	if err := c.publish(ctx, RetryQueue(c.QueueName, delay), msg.Body, amqp.Table{AttemptHeader: int32(attempt + 1)}); err != nil {
+		log.Printf("consumer: schedule retry for %s: %v", trackingID, err)
+		msg.Nack(false, true)
+		return
+	}
+	_ = c.Engine.Repo.CreateMessageEvent(trackingID, fmt.Sprintf("retry %d scheduled in %s (tier %d)", attempt+1, shortDuration(delay), tier))
+	msg.Ack(false)
+}
+
+// retryTier picks the retry tier (1-based) and delay after a failed attempt.
+// Attempts beyond the last tier keep using the longest delay.
+func retryTier(attempt int, delays []time.Duration) (int, time.Duration) {
+	i := attempt - 1
+	if i >= len(delays) {
+		i = len(delays) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i + 1, delays[i]
+}
+
+// publish sends body to queue through the default exchange and waits until
+// the broker confirms it, so callers may settle the original delivery
+// afterwards without risking the message. The lock only guards reading the
+// channel; the broker round-trip runs without it so Status is never blocked.
+func (c *Consumer) publish(ctx context.Context, queue string, body []byte, headers amqp.Table) error {
+	c.mu.Lock()
+	ch := c.pubCh
+	c.mu.Unlock()
+	if ch == nil {
+		return errors.New("consumer not started")
+	}
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Headers:      headers,
+		Body:         body,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errors.New("message nacked by broker")
+	}
+	return nil
+}
+
+// drainDeadLetters moves messages from the dead-letter queue into the database.
+func (c *Consumer) drainDeadLetters(ctx context.Context, dead <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-dead:
+			if !ok {
+				return
+			}
+			dl := deadLetterFromDelivery(msg)
+			if payload, err := contract.Decode(msg.Body); dl.Reason == "expired" && err == nil {
+				// the message outlived its TTL while queued; that is an
+				// outcome for the message, not a poison message
+				if err := c.Engine.ExpireInQueue(payload); err != nil {
+					log.Printf("consumer: expire %s: %v", dl.TrackingID, err)
+				}
+				msg.Ack(false)
 				continue
 			}
-			if err := c.Engine.ProcessMessage(payload); err != nil {
+			if err := c.DeadLetters.CreateDeadLetter(&dl); err != nil {
+				log.Printf("consumer: store dead letter: %v", err)
+				// back off instead of spinning while the database is unavailable
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
 				msg.Nack(false, true)
 				continue
 			}
 			msg.Ack(false)
 		}
-	}()
+	}
+}
 
-	return nil
+// deadLetterFromDelivery builds the database record for a dead-lettered delivery.
+func deadLetterFromDelivery(msg amqp.Delivery) models.DeadLetter {
+	dl := models.DeadLetter{Body: string(msg.Body), Attempts: deliveryAttempt(msg.Headers)}
+	if b, err := json.Marshal(msg.Headers); err == nil {
+		dl.Headers = string(b)
+	}
+
+	var payload services.MessagePayload
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		dl.Reason = "malformed payload"
+		return dl
+	}
+	dl.TrackingID = payload.TrackingID
+	dl.Reason = deathReason(msg.Headers)
+	return dl
+}
+
+// deliveryAttempt returns the attempt number recorded on a delivery; the
+// first delivery carries no header and counts as attempt 1.
+func deliveryAttempt(headers amqp.Table) int {
+	switch v := headers[AttemptHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 1
+	}
+}
+
+// deathReason returns the broker's most recent dead-lettering reason.
+func deathReason(headers amqp.Table) string {
+	deaths, ok := headers["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return "unknown"
+	}
+	if death, ok := deaths[0].(amqp.Table); ok {
+		if reason, ok := death["reason"].(string); ok {
+			return reason
+		}
+	}
+	return "unknown"
 }
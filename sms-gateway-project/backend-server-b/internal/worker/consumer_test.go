@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"sms-gateway/shared/contract"
+)
+
+func TestDeliveryAttempt(t *testing.T) {
+	if n := deliveryAttempt(nil); n != 1 {
+		t.Fatalf("expected first delivery to be attempt 1, got %d", n)
+	}
+	if n := deliveryAttempt(amqp.Table{AttemptHeader: int32(3)}); n != 3 {
+		t.Fatalf("expected attempt 3, got %d", n)
+	}
+}
+
+func TestRetryTier(t *testing.T) {
+	delays := []time.Duration{10 * time.Second, time.Minute, 10 * time.Minute}
+	cases := []struct {
+		attempt int
+		tier    int
+		delay   time.Duration
+	}{
+		{1, 1, 10 * time.Second},
+		{2, 2, time.Minute},
+		{3, 3, 10 * time.Minute},
+		{7, 3, 10 * time.Minute},
+	}
+	for _, tc := range cases {
+		tier, delay := retryTier(tc.attempt, delays)
+		if tier != tc.tier || delay != tc.delay {
+			t.Errorf("attempt %d: got tier %d delay %s", tc.attempt, tier, delay)
+		}
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	cases := map[time.Duration]string{
+		10 * time.Second:           "sms.retry.10s",
+		time.Minute:                "sms.retry.1m",
+		10 * time.Minute:           "sms.retry.10m",
+		90 * time.Second:           "sms.retry.1m30s",
+		2 * time.Hour:              "sms.retry.2h",
+		time.Hour + 30*time.Minute: "sms.retry.1h30m",
+	}
+	for delay, want := range cases {
+		if got := RetryQueue("sms", delay); got != want {
+			t.Errorf("%s: expected %s, got %s", delay, want, got)
+		}
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	refused := &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'x-dead-letter-exchange'"}
+	if !isPreconditionFailed(fmt.Errorf("declare: %w", refused)) {
+		t.Fatal("expected a precondition failure")
+	}
+	if isPreconditionFailed(&amqp.Error{Code: amqp.NotFound}) || isPreconditionFailed(nil) {
+		t.Fatal("only PRECONDITION_FAILED must match")
+	}
+}
+
+func TestDeadLetterFromDelivery(t *testing.T) {
+	dl := deadLetterFromDelivery(amqp.Delivery{
+		Body: []byte(`{"tracking_id":"t1"}`),
+		Headers: amqp.Table{
+			AttemptHeader: int32(5),
+			"x-death":     []interface{}{amqp.Table{"reason": "rejected", "queue": "sms"}},
+		},
+	})
+	if dl.TrackingID != "t1" || dl.Reason != "rejected" || dl.Attempts != 5 {
+		t.Fatalf("unexpected dead letter: %+v", dl)
+	}
+
+	dl = deadLetterFromDelivery(amqp.Delivery{Body: []byte("{")})
+	if dl.Reason != "malformed payload" || dl.Body != "{" {
+		t.Fatalf("unexpected dead letter: %+v", dl)
+	}
+}
+
+// TestDecodeServerABody pins the body server A publishes to what the consumer
+// decodes, so a change on either side shows up here.
+func TestDecodeServerABody(t *testing.T) {
+	body := []byte(`{"schema_version":1,"tracking_id":"t1","recipient":"09120000000","text":"hello","providers":["magfa"],"ttl":30,"expires_at":"2024-01-01T12:00:30Z"}`)
+	payload, err := contract.Decode(body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload.TrackingID != "t1" || payload.Text != "hello" || payload.TTL != 30 || payload.ExpiresAt == nil {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  time.Second,
+		1:  2 * time.Second,
+		4:  16 * time.Second,
+		5:  30 * time.Second,
+		50: 30 * time.Second,
+	}
+	for attempt, want := range cases {
+		if got := reconnectDelay(attempt); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestConsumerStatus(t *testing.T) {
+	c := NewConsumer("amqp://unused", "sms", nil, nil, 0, nil, 0)
+	if s := c.Status(); s.State != StateStopped || s.Healthy() {
+		t.Fatalf("unexpected initial status: %+v", s)
+	}
+	c.setState(StateConsuming, nil)
+	since := c.Status().Since
+	c.setState(StateReconnecting, errors.New("connection closed"))
+	s := c.Status()
+	if s.Healthy() || s.LastError != "connection closed" || s.Since.Before(since) {
+		t.Fatalf("unexpected status: %+v", s)
+	}
+	c.setState(StateReconnecting, errors.New("dial failed"))
+	if again := c.Status(); !again.Since.Equal(s.Since) || again.LastError != "dial failed" {
+		t.Fatalf("repeated state must keep its start time: %+v", again)
+	}
+}
+
+func TestShutdownBeforeStart(t *testing.T) {
+	c := NewConsumer("amqp://unused", "sms", nil, nil, 0, nil, 0)
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// a second call must not panic on the closed channel
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWorkStopsTakingDeliveriesOnShutdown(t *testing.T) {
+	c := NewConsumer("amqp://unused", "sms", nil, nil, 0, nil, 0)
+	msgs := make(chan amqp.Delivery, 1)
+	msgs <- amqp.Delivery{Body: []byte("{")}
+	c.Shutdown(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		c.work(context.Background(), msgs)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop")
+	}
+	if len(msgs) != 1 {
+		t.Fatal("worker took a delivery after shutdown")
+	}
+}
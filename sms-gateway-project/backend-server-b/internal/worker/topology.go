@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetterExchange returns the exchange that receives messages rejected
+// from queue.
+func DeadLetterExchange(queue string) string { return queue + ".dlx" }
+
+// DeadLetterQueue returns the queue that collects messages rejected from queue.
+func DeadLetterQueue(queue string) string { return queue + ".dlq" }
+
+// RetryQueue returns the queue that holds messages from queue for delay
+// before handing them back, e.g. "sms.retry.10s".
+func RetryQueue(queue string, delay time.Duration) string {
+	return queue + ".retry." + shortDuration(delay)
+}
+
+// shortDuration formats d without zero-valued trailing units ("1m" rather than "1m0s").
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// QueueArgs returns the arguments queue is declared with. Every client that
+// declares the queue must pass the same arguments.
+func QueueArgs(queue string) amqp.Table {
+	return amqp.Table{
+		"x-dead-letter-exchange":    DeadLetterExchange(queue),
+		"x-dead-letter-routing-key": queue,
+	}
+}
+
+// DeclareTopology declares queue together with its dead-letter exchange and
+// queue, and one retry queue per delay. Retry queues have no consumers: their
+// messages expire after the delay and are dead-lettered back onto queue.
+func DeclareTopology(conn *amqp.Connection, queue string, retryDelays []time.Duration) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := ch.ExchangeDeclare(DeadLetterExchange(queue), amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDeclare(DeadLetterQueue(queue), true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := ch.QueueBind(DeadLetterQueue(queue), queue, DeadLetterExchange(queue), false, nil); err != nil {
+		return err
+	}
+	for _, delay := range retryDelays {
+		args := amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}
+		if _, err := ch.QueueDeclare(RetryQueue(queue, delay), true, false, false, false, args); err != nil {
+			return err
+		}
+	}
+	return declareMainQueue(conn, queue)
+}
+
+// declareMainQueue declares queue with QueueArgs. The broker refuses to
+// redeclare a queue with different arguments, so a queue created before
+// dead-lettering was added is replaced when it is empty. A non-empty one is
+// reported and has to be drained first, see the README.
+func declareMainQueue(conn *amqp.Connection, queue string) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	_, err = ch.QueueDeclare(queue, true, false, false, false, QueueArgs(queue))
+	if !isPreconditionFailed(err) {
+		return err
+	}
+
+	// the refused declaration closed the channel
+	ch, err = conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if _, err := ch.QueueDelete(queue, false, true, false); err != nil {
+		return fmt.Errorf("queue %s exists with other arguments and is not empty; drain and delete it, then restart: %w", queue, err)
+	}
+	log.Printf("topology: redeclared queue %s with dead-letter arguments", queue)
+	_, err = ch.QueueDeclare(queue, true, false, false, false, QueueArgs(queue))
+	return err
+}
+
+// isPreconditionFailed reports whether err is the broker refusing a
+// declaration that does not match the existing entity.
+func isPreconditionFailed(err error) bool {
+	var aerr *amqp.Error
+	return errors.As(err, &aerr) && aerr.Code == amqp.PreconditionFailed
+}
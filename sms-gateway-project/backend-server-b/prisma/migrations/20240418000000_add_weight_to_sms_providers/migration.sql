@@ -0,0 +1 @@
+ALTER TABLE "sms_providers" ADD COLUMN "weight" INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE "sms_providers" ADD COLUMN "max_concurrency" INTEGER NOT NULL DEFAULT 0;
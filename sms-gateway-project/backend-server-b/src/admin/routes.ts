@@ -5,11 +5,18 @@ import { providerRegistry } from '../providers';
 import { z } from 'zod';
 
 const priorityGuard = z.number().int().min(0).max(100);
+const weightGuard = z.number().int().min(1);
+// 0 lets the worker send through the provider without a concurrency cap
+const maxConcurrencyGuard = z.number().int().min(0);
 const ProviderCreateSchema = z.object({
   priority: priorityGuard,
+  weight: weightGuard.optional(),
+  max_concurrency: maxConcurrencyGuard.optional(),
 }).passthrough();
 const ProviderUpdateSchema = z.object({
   priority: priorityGuard.optional(),
+  weight: weightGuard.optional(),
+  max_concurrency: maxConcurrencyGuard.optional(),
 }).passthrough();
 
 export const adminRouter = express.Router();
@@ -8,7 +8,14 @@ const QUEUE = 'sms.outbound';
 export async function startConsumer(amqpUrl: string) {
   const conn = await amqp.connect(amqpUrl);
   const ch = await conn.createChannel();
-  await ch.assertQueue(QUEUE, { durable: true });
+  // must match the arguments the Go worker and server A declare the queue with
+  await ch.assertQueue(QUEUE, {
+    durable: true,
+    arguments: {
+      'x-dead-letter-exchange': `${QUEUE}.dlx`,
+      'x-dead-letter-routing-key': QUEUE
+    }
+  });
 
   ch.consume(QUEUE, async (msg: ConsumeMessage | null) => {
     if (!msg) return;
@@ -0,0 +1,101 @@
+// Package contract defines the messages exchanged between server A and
+// server B over RabbitMQ. Both servers import it so the producer and the
+// consumer always agree on the wire format.
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of MessagePayload produced by this package.
+// Bump it whenever a change would not be understood by an older consumer.
+const SchemaVersion = 1
+
+// ErrInvalidPayload is wrapped by every validation failure.
+var ErrInvalidPayload = errors.New("invalid message payload")
+
+// MessagePayload is the body of a message on the SMS queue.
+type MessagePayload struct {
+	SchemaVersion int      `json:"schema_version"`
+	TrackingID    string   `json:"tracking_id"`
+	Recipient     string   `json:"recipient"`
+	Text          string   `json:"text"`
+	Providers     []string `json:"providers"`
+	// TTL is the message lifetime in seconds; ExpiresAt is the absolute
+	// deadline derived from it when the message was accepted.
+	TTL       int        `json:"ttl"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// BatchID groups the messages of one bulk request; BatchSize is the
+	// number of messages in that batch.
+	BatchID   string `json:"batch_id,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+	// SendAt delays sending until the given time; nil sends immediately.
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// Client is the name of the API client that submitted the message.
+	Client string `json:"client,omitempty"`
+}
+
+// Scheduled reports whether the message must be held until a later time.
+func (m MessagePayload) Scheduled(now time.Time) bool {
+	return m.SendAt != nil && now.Before(*m.SendAt)
+}
+
+// Expired reports whether the message must no longer be sent at now.
+func (m MessagePayload) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// Validate checks that the payload is complete and of a supported version.
+func (m MessagePayload) Validate() error {
+	switch {
+	case m.SchemaVersion < 1 || m.SchemaVersion > SchemaVersion:
+		return fmt.Errorf("%w: unsupported schema version %d", ErrInvalidPayload, m.SchemaVersion)
+	case strings.TrimSpace(m.TrackingID) == "":
+		return fmt.Errorf("%w: tracking_id is required", ErrInvalidPayload)
+	case strings.TrimSpace(m.Recipient) == "":
+		return fmt.Errorf("%w: recipient is required", ErrInvalidPayload)
+	case m.Text == "":
+		return fmt.Errorf("%w: text is required", ErrInvalidPayload)
+	case m.TTL < 0:
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidPayload)
+	case m.BatchID != "" && m.BatchSize < 1:
+		return fmt.Errorf("%w: batch_size is required with batch_id", ErrInvalidPayload)
+	}
+	return nil
+}
+
+// Encode stamps the current schema version on m, validates it and returns
+// its JSON encoding.
+func Encode(m MessagePayload) ([]byte, error) {
+	m.SchemaVersion = SchemaVersion
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// Decode parses and validates a queue message. Bodies published before the
+// schema was versioned carry no schema_version and the text under "message";
+// they are read as version 1. On a validation error the decoded payload is
+// still returned so callers can log its tracking id.
+func Decode(body []byte) (MessagePayload, error) {
+	var wire struct {
+		MessagePayload
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &wire); err != nil {
+		return MessagePayload{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	m := wire.MessagePayload
+	if m.SchemaVersion == 0 {
+		m.SchemaVersion = 1
+	}
+	if m.Text == "" {
+		m.Text = wire.Message
+	}
+	return m, m.Validate()
+}
@@ -0,0 +1,86 @@
+package contract
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	in := MessagePayload{
+		TrackingID: "t1",
+		Recipient:  "09120000000",
+		Text:       "hello",
+		Providers:  []string{"magfa"},
+		TTL:        30,
+		ExpiresAt:  &expiresAt,
+	}
+	body, err := Encode(in)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, err := Decode(body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.SchemaVersion != SchemaVersion || out.Text != "hello" || out.Recipient != in.Recipient ||
+		out.TTL != 30 || len(out.Providers) != 1 || !out.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unexpected payload: %+v", out)
+	}
+}
+
+func TestDecodeLegacyBody(t *testing.T) {
+	out, err := Decode([]byte(`{"tracking_id":"t1","recipient":"0912","message":"hi","providers":null,"ttl":0}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.SchemaVersion != 1 || out.Text != "hi" {
+		t.Fatalf("unexpected payload: %+v", out)
+	}
+}
+
+func TestDecodeRejectsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"malformed":      `{`,
+		"no text":        `{"schema_version":1,"tracking_id":"t1","recipient":"0912"}`,
+		"no recipient":   `{"schema_version":1,"tracking_id":"t1","text":"hi"}`,
+		"future version": `{"schema_version":99,"tracking_id":"t1","recipient":"0912","text":"hi"}`,
+		"negative ttl":   `{"schema_version":1,"tracking_id":"t1","recipient":"0912","text":"hi","ttl":-1}`,
+	}
+	for name, body := range cases {
+		if _, err := Decode([]byte(body)); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: expected ErrInvalidPayload, got %v", name, err)
+		}
+	}
+}
+
+func TestDecodeKeepsTrackingIDOnError(t *testing.T) {
+	out, err := Decode([]byte(`{"schema_version":1,"tracking_id":"t1","recipient":"0912"}`))
+	if err == nil || out.TrackingID != "t1" {
+		t.Fatalf("expected tracking id with error, got %+v %v", out, err)
+	}
+}
+
+func TestEncodeRejectsInvalid(t *testing.T) {
+	if _, err := Encode(MessagePayload{TrackingID: "t1", Recipient: "0912"}); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidPayload, got %v", err)
+	}
+}
+
+func TestBatchFields(t *testing.T) {
+	in := MessagePayload{TrackingID: "t1", Recipient: "0912", Text: "hi", BatchID: "b1", BatchSize: 2}
+	body, err := Encode(in)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, err := Decode(body)
+	if err != nil || out.BatchID != "b1" || out.BatchSize != 2 {
+		t.Fatalf("unexpected payload: %+v %v", out, err)
+	}
+
+	in.BatchSize = 0
+	if _, err := Encode(in); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidPayload, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+module sms-gateway/shared
+
+go 1.21
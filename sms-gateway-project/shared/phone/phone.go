@@ -0,0 +1,118 @@
+// Package phone normalizes recipient numbers to E.164 (for example
+// +989121234567) so both servers store and compare them in one form.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCountry is the country national numbers are assumed to belong to
+// when none is configured.
+const DefaultCountry = "IR"
+
+// ErrInvalidNumber is wrapped by every error Normalize returns.
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+// Country describes how national numbers of a country are written.
+type Country struct {
+	// CallingCode is the international prefix without "+", e.g. "98".
+	CallingCode string
+	// TrunkPrefix is dialled before national numbers, e.g. "0".
+	TrunkPrefix string
+	// NationalLength is the number of digits after the calling code.
+	NationalLength int
+}
+
+// Countries lists the countries national numbers can be normalized for, by
+// ISO 3166 code. International numbers of other countries are accepted too,
+// but only checked against the E.164 length limits.
+var Countries = map[string]Country{
+	"IR": {CallingCode: "98", TrunkPrefix: "0", NationalLength: 10},
+	"AE": {CallingCode: "971", TrunkPrefix: "0", NationalLength: 9},
+	"TR": {CallingCode: "90", TrunkPrefix: "0", NationalLength: 10},
+	"GB": {CallingCode: "44", TrunkPrefix: "0", NationalLength: 10},
+	"US": {CallingCode: "1", TrunkPrefix: "1", NationalLength: 10},
+}
+
+// E.164 allows at most 15 digits; shorter numbers are not routable by SMS.
+const (
+	minDigits = 8
+	maxDigits = 15
+)
+
+// separators are stripped from numbers before they are parsed.
+var separators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", "\u200c", "")
+
+// Normalize returns raw in E.164 form. Numbers may be written internationally
+// (+98…, 0098…, 98…) or nationally (0912…, 912…) for the given default
+// country, with Persian or Arabic digits and common separators.
+func Normalize(raw, country string) (string, error) {
+	c, ok := Countries[strings.ToUpper(country)]
+	if !ok {
+		return "", fmt.Errorf("unknown country %q", country)
+	}
+
+	s := separators.Replace(toASCIIDigits(strings.TrimSpace(raw)))
+	if s == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidNumber)
+	}
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		s, international = s[1:], true
+	case strings.HasPrefix(s, "00"):
+		s, international = s[2:], true
+	}
+	if !isDigits(s) {
+		return "", fmt.Errorf("%w: %q contains characters other than digits", ErrInvalidNumber, raw)
+	}
+
+	if !international {
+		switch {
+		case len(s) == len(c.TrunkPrefix)+c.NationalLength && strings.HasPrefix(s, c.TrunkPrefix):
+			s = c.CallingCode + s[len(c.TrunkPrefix):]
+		case len(s) == c.NationalLength:
+			s = c.CallingCode + s
+		}
+	}
+
+	if s[0] == '0' {
+		return "", fmt.Errorf("%w: %q has no valid country code", ErrInvalidNumber, raw)
+	}
+	if len(s) < minDigits || len(s) > maxDigits {
+		return "", fmt.Errorf("%w: %q must have %d to %d digits", ErrInvalidNumber, raw, minDigits, maxDigits)
+	}
+	for _, known := range Countries {
+		if strings.HasPrefix(s, known.CallingCode) && len(s) != len(known.CallingCode)+known.NationalLength {
+			return "", fmt.Errorf("%w: %q has the wrong length for +%s", ErrInvalidNumber, raw, known.CallingCode)
+		}
+	}
+	return "+" + s, nil
+}
+
+// toASCIIDigits replaces Persian and Arabic-Indic digits with ASCII ones.
+func toASCIIDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + (r - '۰')
+		case r >= '٠' && r <= '٩':
+			return '0' + (r - '٠')
+		}
+		return r
+	}, s)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"09121234567":        "+989121234567",
+		"9121234567":         "+989121234567",
+		"989121234567":       "+989121234567",
+		"+989121234567":      "+989121234567",
+		"00989121234567":     "+989121234567",
+		"+98 912 123-4567":   "+989121234567",
+		"(0912) 123 45 67":   "+989121234567",
+		"۰۹۱۲۱۲۳۴۵۶۷":        "+989121234567",
+		"٠٩١٢١٢٣٤٥٦٧":        "+989121234567",
+		"+971501234567":      "+971501234567",
+		"+33612345678":       "+33612345678",
+		" +1 (415) 555-0100": "+14155550100",
+	}
+	for raw, want := range cases {
+		got, err := Normalize(raw, "IR")
+		if err != nil || got != want {
+			t.Errorf("%q: expected %s, got %q %v", raw, want, got, err)
+		}
+	}
+}
+
+func TestNormalizeOtherDefaultCountry(t *testing.T) {
+	got, err := Normalize("0501234567", "ae")
+	if err != nil || got != "+971501234567" {
+		t.Fatalf("expected +971501234567, got %q %v", got, err)
+	}
+	if _, err := Normalize("0501234567", "XX"); err == nil {
+		t.Fatal("unknown country accepted")
+	}
+}
+
+func TestNormalizeRejectsMalformedNumbers(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"  ",
+		"0912abc4567",
+		"091212345",
+		"0912123456789",
+		"+98912123456",
+		"+9891212345678",
+		"+0912123456",
+		"+1234567",
+		"+1234567890123456",
+		"++989121234567",
+	} {
+		if got, err := Normalize(raw, "IR"); !errors.Is(err, ErrInvalidNumber) {
+			t.Errorf("%q: expected ErrInvalidNumber, got %q %v", raw, got, err)
+		}
+	}
+}
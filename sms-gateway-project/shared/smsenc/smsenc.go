@@ -0,0 +1,109 @@
+// Package smsenc detects the encoding an SMS text is sent with and counts the
+// segments it occupies. Texts made only of GSM 03.38 characters are sent as
+// GSM-7, anything else (Persian text, emoji) as UCS-2.
+package smsenc
+
+import "strings"
+
+// Encoding is the character encoding of an SMS.
+type Encoding string
+
+// Supported encodings.
+const (
+	GSM7 Encoding = "GSM-7"
+	UCS2 Encoding = "UCS-2"
+)
+
+// Segment capacities, in septets for GSM-7 and UTF-16 code units for UCS-2.
+// A concatenated message loses room in every part to the concatenation header.
+const (
+	GSM7SingleLength = 160
+	GSM7PartLength   = 153
+	UCS2SingleLength = 70
+	UCS2PartLength   = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, without the escape character.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds the characters sent as an escape plus one septet.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Info describes how a text is sent.
+type Info struct {
+	Encoding Encoding `json:"encoding"`
+	// Units is the length in septets (GSM-7) or UTF-16 code units (UCS-2).
+	Units int `json:"units"`
+	// Segments is the number of SMS parts; an empty text has none.
+	Segments int `json:"segments"`
+}
+
+// Detect returns the encoding text is sent with.
+func Detect(text string) Encoding {
+	for _, r := range text {
+		if gsm7Cost(r) == 0 {
+			return UCS2
+		}
+	}
+	return GSM7
+}
+
+// Analyze returns the encoding, length and segment count of text.
+func Analyze(text string) Info {
+	enc := Detect(text)
+	cost, single, part := ucs2Cost, UCS2SingleLength, UCS2PartLength
+	if enc == GSM7 {
+		cost, single, part = gsm7Cost, GSM7SingleLength, GSM7PartLength
+	}
+
+	info := Info{Encoding: enc}
+	for _, r := range text {
+		info.Units += cost(r)
+	}
+	switch {
+	case info.Units == 0:
+		return info
+	case info.Units <= single:
+		info.Segments = 1
+		return info
+	}
+
+	// characters are never split across parts, so a part may end short of
+	// its capacity when an escape sequence or surrogate pair does not fit
+	info.Segments = 1
+	used := 0
+	for _, r := range text {
+		c := cost(r)
+		if used+c > part {
+			info.Segments++
+			used = 0
+		}
+		used += c
+	}
+	return info
+}
+
+// Segments returns the number of SMS parts text occupies.
+func Segments(text string) int {
+	return Analyze(text).Segments
+}
+
+// gsm7Cost returns the septets r takes in GSM-7, or 0 if it has no GSM-7 form.
+func gsm7Cost(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7Basic, r):
+		return 1
+	case strings.ContainsRune(gsm7Extension, r):
+		return 2
+	}
+	return 0
+}
+
+// ucs2Cost returns the UTF-16 code units r takes.
+func ucs2Cost(r rune) int {
+	if r > 0xFFFF {
+		return 2
+	}
+	return 1
+}
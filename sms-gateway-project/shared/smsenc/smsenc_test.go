@@ -0,0 +1,55 @@
+package smsenc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]Encoding{
+		"Hello, world!":      GSM7,
+		"Price: 10€ [promo]": GSM7,
+		"Ünïcode":            UCS2,
+		"سلام":               UCS2,
+		"ok 👍":               UCS2,
+		"":                   GSM7,
+	}
+	for text, want := range cases {
+		if got := Detect(text); got != want {
+			t.Errorf("%q: expected %s, got %s", text, want, got)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		text     string
+		enc      Encoding
+		units    int
+		segments int
+	}{
+		{"", GSM7, 0, 0},
+		{strings.Repeat("a", 160), GSM7, 160, 1},
+		{strings.Repeat("a", 161), GSM7, 161, 2},
+		{strings.Repeat("a", 306), GSM7, 306, 2},
+		{strings.Repeat("a", 307), GSM7, 307, 3},
+		// escape sequences count twice
+		{strings.Repeat("{", 80), GSM7, 160, 1},
+		{strings.Repeat("{", 81), GSM7, 162, 2},
+		// an escape sequence is not split across parts
+		{strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10), GSM7, 164, 2},
+		{strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), GSM7, 306, 3},
+		{strings.Repeat("س", 70), UCS2, 70, 1},
+		{strings.Repeat("س", 71), UCS2, 71, 2},
+		{strings.Repeat("س", 134), UCS2, 134, 2},
+		{strings.Repeat("س", 135), UCS2, 135, 3},
+		// surrogate pairs take two units and are not split either
+		{strings.Repeat("س", 66) + "👍" + strings.Repeat("س", 5), UCS2, 73, 2},
+	}
+	for _, tc := range cases {
+		info := Analyze(tc.text)
+		if info.Encoding != tc.enc || info.Units != tc.units || info.Segments != tc.segments {
+			t.Errorf("%.20q...: got %+v", tc.text, info)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package template renders message templates shared by both servers. A
+// template has one body per language, and bodies refer to variables with
+// {{name}} placeholders.
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches {{name}}, allowing spaces inside the braces.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Variant is the body of a template in one language.
+type Variant struct {
+	Language string `json:"language"`
+	Body     string `json:"body"`
+}
+
+// Template is a named message text with per-language variants. Server B
+// serves it to server A in this form.
+type Template struct {
+	ID              string    `json:"id"`
+	DefaultLanguage string    `json:"default_language"`
+	Variants        []Variant `json:"variants"`
+}
+
+// Variant returns the body for language, or for the default language when
+// language is empty.
+func (t Template) Variant(language string) (Variant, bool) {
+	if language == "" {
+		language = t.DefaultLanguage
+	}
+	for _, v := range t.Variants {
+		if strings.EqualFold(v.Language, language) {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// MissingVariablesError lists the placeholders a render had no value for.
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("missing variables: %s", strings.Join(e.Names, ", "))
+}
+
+// Variables returns the distinct placeholder names in body in order of first use.
+func Variables(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Render substitutes vars into body. It returns a *MissingVariablesError
+// naming every placeholder without a value; extra variables are ignored.
+func Render(body string, vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range Variables(body) {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", &MissingVariablesError{Names: missing}
+	}
+	return placeholder.ReplaceAllStringFunc(body, func(m string) string {
+		return vars[placeholder.FindStringSubmatch(m)[1]]
+	}), nil
+}
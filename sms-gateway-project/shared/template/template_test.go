@@ -0,0 +1,51 @@
+package template
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	got := Variables("Hi {{name}}, your code is {{ code }}. Bye {{name}}! {{ not-a-var }}")
+	if want := []string{"name", "code"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	out, err := Render("کد شما: {{code}} ({{ name }})", map[string]string{"code": "1234", "name": "Ali", "extra": "x"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if out != "کد شما: 1234 (Ali)" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestRenderMissingVariables(t *testing.T) {
+	_, err := Render("{{a}} {{b}} {{c}}", map[string]string{"b": ""})
+	var missing *MissingVariablesError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingVariablesError, got %v", err)
+	}
+	if !reflect.DeepEqual(missing.Names, []string{"a", "c"}) {
+		t.Fatalf("unexpected missing names: %v", missing.Names)
+	}
+}
+
+func TestTemplateVariant(t *testing.T) {
+	tpl := Template{ID: "otp", DefaultLanguage: "fa", Variants: []Variant{
+		{Language: "fa", Body: "کد: {{code}}"},
+		{Language: "en", Body: "Code: {{code}}"},
+	}}
+	if v, ok := tpl.Variant(""); !ok || v.Language != "fa" {
+		t.Errorf("default variant: %+v %v", v, ok)
+	}
+	if v, ok := tpl.Variant("EN"); !ok || v.Language != "en" {
+		t.Errorf("en variant: %+v %v", v, ok)
+	}
+	if _, ok := tpl.Variant("de"); ok {
+		t.Error("unknown language matched")
+	}
+}